@@ -0,0 +1,103 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/go-github/github"
+
+	"github.com/nlamirault/geronimo/storage"
+)
+
+const checkpointType = "checkpoint"
+
+// repositoryCheckpoints are the synchronization states of the data types of a
+// repository, indexed by data type.
+type repositoryCheckpoints map[string]*storage.Checkpoint
+
+// Since returns the last updated time of a data type, or the zero time if the
+// data type has never been synchronized.
+func (c repositoryCheckpoints) Since(datatype string) time.Time {
+	if checkpoint, ok := c[datatype]; ok && checkpoint.LastUpdated != nil {
+		return *checkpoint.LastUpdated
+	}
+	return time.Time{}
+}
+
+// Update records the last updated time of the data fetched for a data type.
+func (c repositoryCheckpoints) Update(datatype string, updated *time.Time) {
+	checkpoint, ok := c[datatype]
+	if !ok {
+		checkpoint = &storage.Checkpoint{Type: datatype}
+		c[datatype] = checkpoint
+	}
+	checkpoint.LastUpdated = maxTime(checkpoint.LastUpdated, updated)
+}
+
+func checkpointID(owner string, repo *github.Repository, datatype string) string {
+	return options.Layout.ID(owner, *repo.Name,
+		fmt.Sprintf("%d_%s", *repo.ID, datatype))
+}
+
+// loadCheckpoints retrieves the checkpoints of a repository. A rebuild
+// starts without checkpoints.
+func loadCheckpoints(backend storage.Backend, owner string, repo *github.Repository, datatypes ...string) (repositoryCheckpoints, error) {
+	c := repositoryCheckpoints{}
+	if options.Rebuild {
+		return c, nil
+	}
+	index := options.Layout.Index(checkpointType, owner, *repo.Name)
+	for _, datatype := range datatypes {
+		checkpoint := &storage.Checkpoint{
+			Owner:      owner,
+			Repository: *repo.Name,
+			Type:       datatype,
+		}
+		found, err := backend.Get(index, checkpointType,
+			checkpointID(owner, repo, datatype), checkpoint)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			log.Printf("[DEBUG] Repository %s: %s synchronized until %s",
+				*repo.Name, datatype, checkpoint.LastUpdated)
+		}
+		c[datatype] = checkpoint
+	}
+	return c, nil
+}
+
+// saveCheckpoints stores the checkpoints of a repository once all its data has
+// been stored, so an interrupted synchronization restarts from the last
+// successful one.
+func saveCheckpoints(backend storage.Backend, owner string, repo *github.Repository, c repositoryCheckpoints) error {
+	date := options.Date
+	index := options.Layout.Index(checkpointType, owner, *repo.Name)
+	for datatype, checkpoint := range c {
+		checkpoint.Owner = owner
+		checkpoint.Repository = *repo.Name
+		checkpoint.LastRun = &date
+		err := backend.Upsert(index, checkpointType,
+			checkpointID(owner, repo, datatype), checkpoint)
+		if err != nil {
+			return fmt.Errorf("can't store %s checkpoint: %s",
+				datatype, err.Error())
+		}
+	}
+	return nil
+}
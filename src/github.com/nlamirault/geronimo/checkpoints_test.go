@@ -0,0 +1,79 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+
+	"github.com/nlamirault/geronimo/storage"
+)
+
+func TestCheckpoints(t *testing.T) {
+	first := time.Date(2015, 10, 1, 0, 0, 0, 0, time.UTC)
+	last := time.Date(2015, 12, 1, 0, 0, 0, 0, time.UTC)
+	checkpoints := repositoryCheckpoints{
+		"issue": &storage.Checkpoint{Type: "issue"},
+	}
+	if since := checkpoints.Since("issue"); !since.IsZero() {
+		t.Fatalf("Invalid checkpoint without synchronization: %s", since)
+	}
+	checkpoints.Update("issue", &last)
+	checkpoints.Update("issue", &first)
+	checkpoints.Update("issue", nil)
+	if since := checkpoints.Since("issue"); !since.Equal(last) {
+		t.Fatalf("Invalid checkpoint: %s", since)
+	}
+	if since := checkpoints.Since("commit"); !since.IsZero() {
+		t.Fatalf("Invalid checkpoint of unknown type: %s", since)
+	}
+}
+
+func TestSaveAndLoadCheckpoints(t *testing.T) {
+	last := time.Date(2015, 12, 1, 0, 0, 0, 0, time.UTC)
+	id, name := 42, "geronimo"
+	repo := &github.Repository{ID: &id, Name: &name}
+	backend := storage.NewMemory()
+	options.Layout = &storage.Layout{Strategy: storage.LayoutType}
+	checkpoints, err := loadCheckpoints(backend, "nlamirault", repo, "issue", "commit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if since := checkpoints.Since("issue"); !since.IsZero() {
+		t.Fatalf("Invalid checkpoint without synchronization: %s", since)
+	}
+	checkpoints.Update("issue", &last)
+	if err := saveCheckpoints(backend, "nlamirault", repo, checkpoints); err != nil {
+		t.Fatal(err)
+	}
+	var checkpoint storage.Checkpoint
+	found, err := backend.Get("geronimo_checkpoint", "checkpoint",
+		"nlamirault/geronimo/42_issue", &checkpoint)
+	if err != nil || !found || checkpoint.Owner != "nlamirault" {
+		t.Fatalf("Invalid stored document: %t %#v", found, checkpoint)
+	}
+	checkpoints, err = loadCheckpoints(backend, "nlamirault", repo, "issue", "commit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if since := checkpoints.Since("issue"); !since.Equal(last) {
+		t.Fatalf("Invalid stored checkpoint: %s", since)
+	}
+	if since := checkpoints.Since("commit"); !since.IsZero() {
+		t.Fatalf("Invalid stored checkpoint: %s", since)
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nlamirault/geronimo/config"
+)
+
+// defaultCommand is the command run without arguments.
+const defaultCommand = "sync"
+
+// command is a command of the command line. The configuration is loaded and
+// validated by the shared setup before running a command, unless noConfig
+// is set. With noValidate, the command runs with an invalid configuration.
+type command struct {
+	name        string
+	args        string
+	description string
+	help        string
+	flags       *flag.FlagSet
+	noConfig    bool
+	noValidate  bool
+	subcommands []*command
+	run         func(conf *config.Configuration, args []string) error
+}
+
+// newCommand creates a command with an empty set of flags.
+func newCommand(name string, args string, description string) *command {
+	cmd := &command{
+		name:        name,
+		args:        args,
+		description: description,
+		flags:       flag.NewFlagSet(name, flag.ContinueOnError),
+	}
+	cmd.flags.SetOutput(os.Stderr)
+	cmd.flags.Usage = func() {
+		cmd.usage(os.Stderr)
+	}
+	return cmd
+}
+
+// usage writes the help of a command.
+func (cmd *command) usage(w io.Writer) {
+	line := "geronimo [options] " + cmd.path()
+	if cmd.args != "" {
+		line += " " + cmd.args
+	}
+	fmt.Fprintf(w, "Usage: %s\n\n%s\n", line, cmd.description)
+	if cmd.help != "" {
+		fmt.Fprintf(w, "%s\n", cmd.help)
+	}
+	if len(cmd.subcommands) > 0 {
+		fmt.Fprintf(w, "\nCommands:\n")
+		printCommands(w, cmd.subcommands)
+	}
+	hasFlags := false
+	cmd.flags.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		fmt.Fprintf(w, "\nOptions:\n")
+		cmd.flags.SetOutput(w)
+		cmd.flags.PrintDefaults()
+		cmd.flags.SetOutput(os.Stderr)
+	}
+}
+
+// path returns the names of the command and its parents.
+func (cmd *command) path() string {
+	return cmd.flags.Name()
+}
+
+// printCommands writes the names and descriptions of commands.
+func printCommands(w io.Writer, commands []*command) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.description)
+	}
+	tw.Flush()
+}
+
+// findCommand returns the command named by the first arguments, and the
+// remaining arguments.
+func findCommand(commands []*command, args []string) (*command, []string, error) {
+	if len(args) == 0 {
+		args = []string{defaultCommand}
+	}
+	var cmd *command
+	for len(args) > 0 {
+		var found *command
+		for _, c := range commands {
+			if c.name == args[0] {
+				found = c
+				break
+			}
+		}
+		if found == nil {
+			if cmd == nil {
+				flag.Usage()
+				return nil, nil, fmt.Errorf("unknown command %s", args[0])
+			}
+			break
+		}
+		cmd, args, commands = found, args[1:], found.subcommands
+	}
+	return cmd, args, nil
+}
+
+// runCommand parses the flags of the command named by the arguments, loads
+// the configuration and runs it.
+func runCommand(commands []*command, args []string) error {
+	cmd, args, err := findCommand(commands, args)
+	if err != nil {
+		return err
+	}
+	if err := cmd.flags.Parse(args); err != nil {
+		return err
+	}
+	if cmd.run == nil {
+		cmd.usage(os.Stderr)
+		if cmd.flags.NArg() > 0 {
+			return fmt.Errorf("unknown command %s %s", cmd.path(), cmd.flags.Arg(0))
+		}
+		return fmt.Errorf("missing %s command", cmd.path())
+	}
+	if cmd.noConfig {
+		setupLogging(debug)
+		return cmd.run(nil, cmd.flags.Args())
+	}
+	// Without file found in the XDG locations, the configuration may come
+	// from the environment and the command line only
+	filename, err := getConfigurationFile()
+	if err != nil && (configurationNamed() ||
+		!config.HasEnvironment() && len(configSet) == 0) {
+		return err
+	}
+	conf, err := setup(filename, debug, !cmd.noValidate)
+	if err != nil {
+		return fmt.Errorf("can't setup: %s", err.Error())
+	}
+	return cmd.run(conf, cmd.flags.Args())
+}
+
+// addSubcommands adds subcommands to a command, named after it.
+func (cmd *command) addSubcommands(subcommands ...*command) {
+	for _, sub := range subcommands {
+		sub.flags.Init(cmd.path()+" "+sub.name, flag.ContinueOnError)
+		cmd.subcommands = append(cmd.subcommands, sub)
+	}
+}
+
+// commands returns the commands of geronimo.
+func commands() []*command {
+	configCmd := newCommand("config", "<command>",
+		"Manage the configuration file.")
+	configCmd.addSubcommands(configValidateCommand(), configShowCommand(),
+		configInitCommand())
+	commands := []*command{
+		syncCommand(),
+		daemonCommand(),
+		statusCommand(),
+		reportCommand(),
+		exportCommand(),
+		serveCommand(),
+		reindexCommand(),
+		configCmd,
+		versionCommand(),
+	}
+	return append(commands, helpCommand(commands))
+}
+
+func helpCommand(commands []*command) *command {
+	cmd := newCommand("help", "[command]", "Print the help of a command.")
+	cmd.noConfig = true
+	cmd.run = func(_ *config.Configuration, args []string) error {
+		if len(args) == 0 {
+			flag.Usage()
+			return nil
+		}
+		c, rest, err := findCommand(commands, args)
+		if err != nil {
+			return err
+		}
+		if len(rest) > 0 {
+			return fmt.Errorf("unknown command %s %s", c.path(), rest[0])
+		}
+		c.usage(os.Stdout)
+		return nil
+	}
+	return cmd
+}
+
+func versionCommand() *command {
+	cmd := newCommand("version", "", "Print the version of Geronimo.")
+	cmd.noConfig = true
+	cmd.run = func(_ *config.Configuration, args []string) error {
+		printVersion()
+		return nil
+	}
+	return cmd
+}
+
+func syncCommand() *command {
+	cmd := newCommand("sync", "",
+		"Synchronize the GitHub data of the users and organizations once.")
+	cmd.help = "This is the default command."
+	cmd.flags.BoolVar(&noCache, "no-cache", noCache, "Bypass the GitHub responses cache")
+	types := cmd.flags.String("types", "",
+		"Comma separated data types to synchronize (default all): "+
+			strings.Join(syncTypes, ", "))
+	cmd.run = func(conf *config.Configuration, args []string) error {
+		var datatypes []string
+		if *types != "" {
+			datatypes = strings.Split(*types, ",")
+		}
+		backend, err := openBackend(conf)
+		if err != nil {
+			return fmt.Errorf("can't open storage: %s", err.Error())
+		}
+		defer closeBackend(backend)
+		return synchronizeOnce(conf, backend, datatypes)
+	}
+	return cmd
+}
+
+func daemonCommand() *command {
+	cmd := newCommand("daemon", "", "Synchronize the GitHub data periodically.")
+	cmd.help = "The data types are synchronized according to the schedules of the\n" +
+		"[daemon] section of the configuration, until SIGINT or SIGTERM."
+	cmd.flags.BoolVar(&noCache, "no-cache", noCache, "Bypass the GitHub responses cache")
+	cmd.run = func(conf *config.Configuration, args []string) error {
+		backend, err := openBackend(conf)
+		if err != nil {
+			return fmt.Errorf("can't open storage: %s", err.Error())
+		}
+		defer closeBackend(backend)
+		return runDaemon(conf, backend)
+	}
+	return cmd
+}
+
+func statusCommand() *command {
+	cmd := newCommand("status", "", "Print the last and next runs of the daemon.")
+	cmd.run = func(conf *config.Configuration, args []string) error {
+		return printStatus(conf, os.Stdout)
+	}
+	return cmd
+}
+
+func reindexCommand() *command {
+	cmd := newCommand("reindex", "[alias...]", "Rebuild the Elasticsearch indices.")
+	cmd.help = "The aliases given as arguments, or the aliases of the indices using\n" +
+		"outdated mappings, are copied into new indices."
+	fromGithub := cmd.flags.Bool("from-github", false,
+		"Rebuild all the indices from GitHub instead of copying them")
+	cmd.flags.BoolVar(&noCache, "no-cache", noCache, "Bypass the GitHub responses cache")
+	cmd.run = func(conf *config.Configuration, args []string) error {
+		backend, err := openBackend(conf)
+		if err != nil {
+			return fmt.Errorf("can't open storage: %s", err.Error())
+		}
+		defer closeBackend(backend)
+		// The documents written by a synchronization during the copy
+		// would be lost with the previous indices
+		lock, err := acquireLock(lockFile(conf))
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+		return reindex(conf, backend, *fromGithub, args)
+	}
+	return cmd
+}
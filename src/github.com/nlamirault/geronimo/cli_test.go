@@ -0,0 +1,79 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFindDefaultCommand(t *testing.T) {
+	cmd, args, err := findCommand(commands(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.name != defaultCommand || len(args) != 0 {
+		t.Fatalf("Invalid default command: %s %v", cmd.name, args)
+	}
+}
+
+func TestFindSubcommand(t *testing.T) {
+	cmd, args, err := findCommand(commands(), []string{"config", "init", "-force"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.path() != "config init" || len(args) != 1 || args[0] != "-force" {
+		t.Fatalf("Invalid subcommand: %s %v", cmd.path(), args)
+	}
+}
+
+func TestFindUnknownCommand(t *testing.T) {
+	if _, _, err := findCommand(commands(), []string{"foo"}); err == nil {
+		t.Fatalf("Unknown command found")
+	}
+}
+
+func TestRunMissingSubcommand(t *testing.T) {
+	if err := runCommand(commands(), []string{"config"}); err == nil {
+		t.Fatalf("Missing subcommand run")
+	}
+}
+
+func TestCommandUsage(t *testing.T) {
+	var b bytes.Buffer
+	cmd, _, _ := findCommand(commands(), []string{"config"})
+	cmd.usage(&b)
+	usage := b.String()
+	if !strings.Contains(usage, "validate") || !strings.Contains(usage, "init") {
+		t.Fatalf("Invalid usage: %s", usage)
+	}
+}
+
+func TestGlobalNoCache(t *testing.T) {
+	defer func() { noCache = false }()
+	noCache = true
+	cmd, args, err := findCommand(commands(), []string{"sync"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.flags.Parse(args); err != nil || !noCache {
+		t.Fatalf("Invalid global -no-cache: %t %v", noCache, err)
+	}
+	cmd, _, _ = findCommand(commands(), []string{"sync"})
+	if err := cmd.flags.Parse([]string{"-no-cache=false"}); err != nil || noCache {
+		t.Fatalf("Invalid command -no-cache: %t %v", noCache, err)
+	}
+}
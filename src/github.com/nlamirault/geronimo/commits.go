@@ -0,0 +1,233 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/go-github/github"
+
+	"github.com/nlamirault/geronimo/storage"
+)
+
+// commitsOverlap is the period before the commit checkpoint which is walked
+// again by each synchronization, as commits may be pushed long after their
+// committer date.
+const commitsOverlap = 7 * 24 * time.Hour
+
+// storedCommits returns the SHAs of the commits stored for a repository, or
+// none during a rebuild.
+func storedCommits(backend storage.Backend, owner string, repo *github.Repository) (map[string]bool, error) {
+	shas := map[string]bool{}
+	if options.Rebuild {
+		return shas, nil
+	}
+	documents, err := backend.Query(
+		options.Layout.Index("commit", owner, *repo.Name), "commit",
+		options.Layout.Terms("commit", owner, *repo.Name))
+	if err != nil {
+		return nil, err
+	}
+	for _, document := range documents {
+		var commit storage.Commit
+		if err := json.Unmarshal(*document, &commit); err != nil {
+			return nil, err
+		}
+		shas[commit.SHA] = true
+	}
+	return shas, nil
+}
+
+// retrieveRepositoryCommits retrieves the commits of a repository since a
+// date which are not already stored, up to options.MaxCommits commits. Each
+// commit is retrieved with its statistics and files. complete is false if
+// commits were left out by options.MaxCommits: they are retrieved by the next
+// synchronizations.
+func retrieveRepositoryCommits(client *github.Client, backend storage.Backend, owner string, repo *github.Repository, since time.Time) (commits []*github.RepositoryCommit, complete bool, err error) {
+	stored, err := storedCommits(backend, owner, repo)
+	if err != nil {
+		return nil, false, err
+	}
+	var shas []string
+	complete = true
+	for page := options.From/options.PerPage + 1; page != 0; {
+		c, resp, err := client.Repositories.ListCommits(
+			userLogin(repo.Owner),
+			*repo.Name,
+			&github.CommitsListOptions{
+				ListOptions: github.ListOptions{
+					PerPage: options.PerPage,
+					Page:    page,
+				},
+				Since: since})
+		if err != nil {
+			if resp != nil && resp.StatusCode == http.StatusConflict {
+				// Git repository is empty
+				return nil, true, nil
+			}
+			return nil, false, err
+		}
+		for _, commit := range c {
+			if sha := stringValue(commit.SHA); !stored[sha] {
+				shas = append(shas, sha)
+			}
+		}
+		page = nextPage(resp)
+		if options.MaxCommits > 0 && len(shas) >= options.MaxCommits {
+			complete = len(shas) == options.MaxCommits && page == 0
+			shas = shas[:options.MaxCommits]
+			break
+		}
+	}
+
+	for _, sha := range shas {
+		commit, _, err := client.Repositories.GetCommit(
+			userLogin(repo.Owner), *repo.Name, sha)
+		if err != nil {
+			return nil, false, err
+		}
+		commits = append(commits, commit)
+	}
+	log.Printf("[DEBUG] Repository %s: %d commits (complete: %t)",
+		*repo.Name, len(commits), complete)
+	return commits, complete, nil
+}
+
+func saveCommits(backend storage.Backend, owner string, repo *github.Repository, commits []*github.RepositoryCommit) error {
+	index := options.Layout.Index("commit", owner, *repo.Name)
+	for _, commit := range commits {
+		data := newCommit(repo, commit)
+		data.Owner = owner
+		err := backend.Upsert(index, "commit",
+			options.Layout.ID(owner, *repo.Name, data.SHA), data)
+		if err != nil {
+			return fmt.Errorf("can't store commit %s: %s",
+				data.SHA, err.Error())
+		}
+	}
+	log.Printf("[INFO] Indexed %d commits of %s to index %s",
+		len(commits), *repo.Name, index)
+	return nil
+}
+
+// indexingCommitAuthors computes the statistics of the authors from all the
+// commits stored for a repository, as a synchronization only retrieves the
+// new commits.
+func indexingCommitAuthors(backend storage.Backend, owner string, repo *github.Repository) (map[string]*storage.CommitAuthor, error) {
+	documents, err := backend.Query(
+		options.Layout.Index("commit", owner, *repo.Name), "commit",
+		options.Layout.Terms("commit", owner, *repo.Name))
+	if err != nil {
+		return nil, fmt.Errorf("can't retrieve commits: %s", err.Error())
+	}
+	authors := map[string]*storage.CommitAuthor{}
+	for _, document := range documents {
+		var commit storage.Commit
+		if err := json.Unmarshal(*document, &commit); err != nil {
+			return nil, err
+		}
+		addCommitAuthor(authors, commit)
+	}
+	index := options.Layout.Index("commit_author", owner, *repo.Name)
+	for _, author := range sortedCommitAuthors(authors) {
+		author.Owner = owner
+		err := backend.Upsert(index, "commit_author",
+			options.Layout.ID(owner, *repo.Name, author.Author), author)
+		if err != nil {
+			return nil, fmt.Errorf("can't store commits statistics of %s: %s",
+				author.Author, err.Error())
+		}
+	}
+	return authors, nil
+}
+
+func newCommit(repo *github.Repository, commit *github.RepositoryCommit) storage.Commit {
+	data := storage.Commit{
+		Repository: *repo.Name,
+		SHA:        stringValue(commit.SHA),
+		Author:     userLogin(commit.Author),
+		Committer:  userLogin(commit.Committer),
+		Files:      []string{},
+	}
+	if commit.Commit != nil {
+		data.Message = stringValue(commit.Commit.Message)
+		if author := commit.Commit.Author; author != nil {
+			data.AuthorName = stringValue(author.Name)
+			data.AuthorEmail = stringValue(author.Email)
+			data.Authored = author.Date
+		}
+		if committer := commit.Commit.Committer; committer != nil {
+			data.CommitterName = stringValue(committer.Name)
+			data.CommitterEmail = stringValue(committer.Email)
+			data.Committed = committer.Date
+		}
+	}
+	if commit.Stats != nil {
+		data.Additions = intValue(commit.Stats.Additions)
+		data.Deletions = intValue(commit.Stats.Deletions)
+	}
+	for _, file := range commit.Files {
+		data.Files = append(data.Files, stringValue(file.Filename))
+	}
+	return data
+}
+
+// commitAuthorName identifies the author of a commit: the GitHub login if the
+// commit is linked to a GitHub account, the git email otherwise.
+func commitAuthorName(commit storage.Commit) string {
+	if commit.Author != "" {
+		return commit.Author
+	}
+	if commit.AuthorEmail != "" {
+		return commit.AuthorEmail
+	}
+	return commit.AuthorName
+}
+
+// addCommitAuthor adds a commit to the statistics of its author.
+func addCommitAuthor(authors map[string]*storage.CommitAuthor, commit storage.Commit) {
+	name := commitAuthorName(commit)
+	author, ok := authors[name]
+	if !ok {
+		author = &storage.CommitAuthor{
+			Repository: commit.Repository,
+			Author:     name,
+		}
+		authors[name] = author
+	}
+	author.Commits++
+	author.Additions += commit.Additions
+	author.Deletions += commit.Deletions
+	author.First = minTime(author.First, commit.Authored)
+	author.Last = maxTime(author.Last, commit.Authored)
+}
+
+func sortedCommitAuthors(authors map[string]*storage.CommitAuthor) []*storage.CommitAuthor {
+	var names []string
+	for name := range authors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sorted []*storage.CommitAuthor
+	for _, name := range names {
+		sorted = append(sorted, authors[name])
+	}
+	return sorted
+}
@@ -0,0 +1,120 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+
+	"github.com/nlamirault/geronimo/storage"
+)
+
+// newTestCommitsServer serves a repository of 3 commits, newest first, 2 per
+// page.
+func newTestCommitsServer(t *testing.T) (*httptest.Server, *github.Client) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/nlamirault/geronimo/commits":
+			if r.URL.Query().Get("page") == "2" {
+				fmt.Fprint(w, `[{"sha": "c1"}]`)
+				return
+			}
+			w.Header().Set("Link", `<`+"http://"+r.Host+r.URL.Path+`?page=2>; rel="next"`)
+			fmt.Fprint(w, `[{"sha": "c3"}, {"sha": "c2"}]`)
+		default:
+			var sha string
+			fmt.Sscanf(r.URL.Path, "/repos/nlamirault/geronimo/commits/%s", &sha)
+			fmt.Fprintf(w, `{"sha": %q}`, sha)
+		}
+	}))
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	return server, client
+}
+
+func TestRetrieveMaxCommits(t *testing.T) {
+	server, client := newTestCommitsServer(t)
+	defer server.Close()
+	options.Layout = &storage.Layout{Strategy: storage.LayoutType}
+	options.From = DefaultFrom
+	options.PerPage = 2
+	options.MaxCommits = 1
+	defer func() { options.MaxCommits = 0 }()
+	login, name := "nlamirault", "geronimo"
+	repo := &github.Repository{Name: &name, Owner: &github.User{Login: &login}}
+	backend := storage.NewMemory()
+	commits, complete, err := retrieveRepositoryCommits(client, backend, "nlamirault", repo, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 1 || *commits[0].SHA != "c3" || complete {
+		t.Fatalf("Invalid commits: %v %t", commits, complete)
+	}
+
+	// The next synchronizations retrieve the commits left out
+	options.MaxCommits = 2
+	if err := saveCommits(backend, "nlamirault", repo, commits); err != nil {
+		t.Fatal(err)
+	}
+	commits, complete, err = retrieveRepositoryCommits(client, backend, "nlamirault", repo, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 2 || *commits[0].SHA != "c2" || *commits[1].SHA != "c1" || !complete {
+		t.Fatalf("Invalid remaining commits: %v %t", commits, complete)
+	}
+}
+
+func TestCommitAuthorStatistics(t *testing.T) {
+	first := time.Date(2015, 10, 1, 0, 0, 0, 0, time.UTC)
+	last := time.Date(2015, 12, 1, 0, 0, 0, 0, time.UTC)
+	authors := map[string]*storage.CommitAuthor{}
+	addCommitAuthor(authors, storage.Commit{
+		Repository: "geronimo",
+		Author:     "nlamirault",
+		Authored:   &last,
+		Additions:  10,
+		Deletions:  2,
+	})
+	addCommitAuthor(authors, storage.Commit{
+		Repository: "geronimo",
+		Author:     "nlamirault",
+		Authored:   &first,
+		Additions:  5,
+	})
+	addCommitAuthor(authors, storage.Commit{
+		Repository:  "geronimo",
+		AuthorEmail: "foo@bar.com",
+	})
+
+	sorted := sortedCommitAuthors(authors)
+	if len(sorted) != 2 || sorted[0].Author != "foo@bar.com" ||
+		sorted[1].Author != "nlamirault" {
+		t.Fatalf("Invalid authors: %v", sorted)
+	}
+	author := sorted[1]
+	if author.Commits != 2 || author.Additions != 15 || author.Deletions != 2 {
+		t.Fatalf("Invalid author statistics: %#v", author)
+	}
+	if !author.First.Equal(first) || !author.Last.Equal(last) {
+		t.Fatalf("Invalid author dates: %#v", author)
+	}
+}
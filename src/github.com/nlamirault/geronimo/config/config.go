@@ -15,7 +15,8 @@
 package config
 
 import (
-	//"fmt"
+	"fmt"
+	"io/ioutil"
 	"log"
 
 	"github.com/BurntSushi/toml"
@@ -23,20 +24,105 @@ import (
 
 // NSQConfig is the configuration for NSQ.
 type NSQConfig struct {
-	Topic   string `json:"topic"`
-	Channel string `json:"channel"`
-	Lookupd string `json:"lookup_address"`
+	Topic   string `toml:"topic"`
+	Channel string `toml:"channel"`
+	// Lookupd is the host:port of the HTTP address of nsqlookupd
+	Lookupd string `toml:"lookupd"`
 }
 
 // GithubConfig is the Github configuration
 type GithubConfig struct {
-	APIToken string `toml:"api_token"`
+	APIToken string `toml:"api_token" secret:"true"`
 	User     string `toml:"user"`
+	// Users are the users to synchronize, in addition to User
+	Users []string `toml:"users"`
+	// Organizations are the organizations to synchronize
+	Organizations []string `toml:"organizations"`
+	// CacheDir is the directory of the GitHub responses cache
+	CacheDir string `toml:"cache_dir"`
+	// CacheSize is the maximum size of the cache in megabytes
+	CacheSize int64 `toml:"cache_size"`
 }
 
 // ElasticsearchConfig is the Elasticsearch configuration
 type ElasticsearchConfig struct {
 	Host string `toml:"host"`
+	// BulkActions is the number of documents of a bulk request
+	BulkActions int `toml:"bulk_actions"`
+	// BulkFlushInterval is the delay in seconds after which the pending
+	// documents are indexed
+	BulkFlushInterval int `toml:"bulk_flush_interval"`
+	// BulkWorkers is the number of bulk requests sent in parallel
+	BulkWorkers int `toml:"bulk_workers"`
+	// BulkMaxRetries is the number of retries of the documents rejected
+	// because Elasticsearch is overloaded
+	BulkMaxRetries int `toml:"bulk_max_retries"`
+}
+
+// SQLiteConfig is the SQLite configuration
+type SQLiteConfig struct {
+	// Path is the database file (default: ~/.local/share/geronimo/geronimo.db)
+	Path string `toml:"path"`
+}
+
+// StorageConfig is the storage configuration
+type StorageConfig struct {
+	// Backend is the name of the storage backend: elasticsearch (default),
+	// sqlite or memory
+	Backend string `toml:"backend"`
+
+	// Layout is the naming strategy of the indices: repository (default),
+	// one index per owner and per repository, or type, one index per
+	// document type
+	Layout string `toml:"layout"`
+
+	// IndexPrefix is prepended to the names of the indices
+	IndexPrefix string `toml:"index_prefix"`
+}
+
+// SyncConfig is the synchronization configuration
+type SyncConfig struct {
+	// CommitsSince is the date (YYYY-MM-DD) of the oldest commit to retrieve
+	CommitsSince string `toml:"commits_since"`
+	// MaxCommits is the maximum number of commits retrieved per repository
+	MaxCommits int `toml:"max_commits"`
+	// Snapshots stores a daily snapshot of the repositories metrics
+	Snapshots bool `toml:"snapshots"`
+	// DeletedRetention is the number of days the documents of a repository
+	// deleted from GitHub are kept, marked as deleted, before being purged.
+	// Zero keeps them forever
+	DeletedRetention int `toml:"deleted_retention"`
+}
+
+// DaemonConfig is the configuration of the daemon mode
+type DaemonConfig struct {
+	// Schedules are the schedules of the data types (repository, issue,
+	// pull_request, commit, contributor, release, tag, star): a duration
+	// (1h30m) to synchronize at this interval, or a time of day (02:30) to
+	// synchronize daily. All the data types are synchronized hourly if none
+	// is configured
+	Schedules map[string]string `toml:"schedules"`
+	// Jitter is the maximum delay in seconds randomly added to each run
+	Jitter int `toml:"jitter"`
+	// LockFile prevents concurrent synchronizations
+	LockFile string `toml:"lock_file"`
+	// StatusFile is the file where the daemon writes the last and next runs
+	StatusFile string `toml:"status_file"`
+}
+
+// FilterConfig is the configuration of the repositories to synchronize.
+// Include and Exclude patterns are globs, or regular expressions when
+// enclosed in slashes. They match the full name (owner/name) of the
+// repositories if they contain a slash, the name otherwise.
+type FilterConfig struct {
+	Include      []string `toml:"include"`
+	Exclude      []string `toml:"exclude"`
+	SkipForks    bool     `toml:"skip_forks"`
+	SkipArchived bool     `toml:"skip_archived"`
+	SkipPrivate  bool     `toml:"skip_private"`
+	MinStars     int      `toml:"min_stars"`
+	Languages    []string `toml:"languages"`
+	Topics       []string `toml:"topics"`
 }
 
 // Configuration is the Geronimo configuration
@@ -44,13 +130,47 @@ type Configuration struct {
 	NSQ           NSQConfig           `toml:"nsq"`
 	Github        GithubConfig        `toml:"github"`
 	ElasticSearch ElasticsearchConfig `toml:"elasticsearch"`
+	SQLite        SQLiteConfig        `toml:"sqlite"`
+	Storage       StorageConfig       `toml:"storage"`
+	Sync          SyncConfig          `toml:"sync"`
+	Daemon        DaemonConfig        `toml:"daemon"`
+	Filter        FilterConfig        `toml:"filter"`
+}
+
+// AllUsers returns the users to synchronize.
+func (c GithubConfig) AllUsers() []string {
+	var users []string
+	if c.User != "" {
+		users = append(users, c.User)
+	}
+	for _, user := range c.Users {
+		if user != c.User {
+			users = append(users, user)
+		}
+	}
+	return users
 }
 
-// Load read the configuration
+// Load read the configuration from filename, unless empty, then overrides
+// the keys set in the GERONIMO_ environment variables. The unknown keys of
+// the file are errors.
 func Load(filename string) (*Configuration, error) {
 	var config Configuration
-	log.Printf("[DEBUG] Load configuration from %s\n", filename)
-	if _, err := toml.DecodeFile(filename, &config); err != nil {
+	if filename != "" {
+		log.Printf("[DEBUG] Load configuration from %s\n", filename)
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		md, err := toml.Decode(string(data), &config)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", filename, err.Error())
+		}
+		if err := unknownKeys(data, md); err != nil {
+			return nil, fmt.Errorf("%s:\n%s", filename, err.Error())
+		}
+	}
+	if err := config.applyEnvironment(); err != nil {
 		return nil, err
 	}
 	return &config, nil
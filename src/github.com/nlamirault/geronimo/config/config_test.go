@@ -45,9 +45,46 @@ lookupd = "lookupd:4161"
 [github]
 api_token = "azerty2468"
 user = "nlamirault"
+cache_dir = "/tmp/geronimo"
+cache_size = 50
+users = ["nlamirault", "portefaix"]
+organizations = ["docker"]
 
 [elasticsearch]
 host = "localhost:9200"
+bulk_actions = 1000
+bulk_flush_interval = 10
+bulk_workers = 4
+bulk_max_retries = 3
+
+[sqlite]
+path = "/tmp/geronimo.db"
+
+[storage]
+backend = "sqlite"
+layout = "repository"
+index_prefix = "acme_"
+
+[sync]
+commits_since = "2015-01-01"
+max_commits = 500
+snapshots = true
+deleted_retention = 30
+
+[daemon]
+jitter = 60
+lock_file = "/tmp/geronimo.lock"
+status_file = "/tmp/geronimo.json"
+
+[daemon.schedules]
+repository = "1h"
+commit = "02:30"
+
+[filter]
+exclude = ["*-old", "/^test-.*$/"]
+skip_forks = true
+min_stars = 5
+languages = ["Go"]
 `)
 	configFile := createConfiguration(t, data)
 	defer os.RemoveAll(configFile.Name())
@@ -55,16 +92,52 @@ host = "localhost:9200"
 	if err != nil {
 		t.Fatal(err)
 	}
-	if conf.ElasticSearch.Host != "localhost:9200" {
+	if conf.ElasticSearch.Host != "localhost:9200" ||
+		conf.ElasticSearch.BulkActions != 1000 ||
+		conf.ElasticSearch.BulkFlushInterval != 10 ||
+		conf.ElasticSearch.BulkWorkers != 4 ||
+		conf.ElasticSearch.BulkMaxRetries != 3 {
 		t.Fatalf("Invalid Elasticsearch conf: %#v", conf)
 	}
+	if conf.Storage.Backend != "sqlite" ||
+		conf.Storage.Layout != "repository" ||
+		conf.Storage.IndexPrefix != "acme_" ||
+		conf.SQLite.Path != "/tmp/geronimo.db" {
+		t.Fatalf("Invalid Storage conf: %#v", conf)
+	}
 	if conf.Github.APIToken != "azerty2468" ||
-		conf.Github.User != "nlamirault" {
+		conf.Github.User != "nlamirault" ||
+		conf.Github.CacheDir != "/tmp/geronimo" ||
+		conf.Github.CacheSize != 50 {
 		t.Fatalf("Invalid Github conf: %#v", conf)
 	}
+	users := conf.Github.AllUsers()
+	if len(users) != 2 || users[0] != "nlamirault" || users[1] != "portefaix" {
+		t.Fatalf("Invalid Github users: %v", users)
+	}
+	if len(conf.Github.Organizations) != 1 ||
+		conf.Github.Organizations[0] != "docker" {
+		t.Fatalf("Invalid Github organizations: %v", conf.Github.Organizations)
+	}
 	if conf.NSQ.Channel != "geronimo" ||
 		conf.NSQ.Lookupd != "lookupd:4161" {
 		t.Fatalf("Invalid NSQ conf: %#v", conf)
 	}
-
+	if conf.Sync.CommitsSince != "2015-01-01" ||
+		conf.Sync.MaxCommits != 500 ||
+		!conf.Sync.Snapshots ||
+		conf.Sync.DeletedRetention != 30 {
+		t.Fatalf("Invalid Sync conf: %#v", conf)
+	}
+	if len(conf.Filter.Exclude) != 2 || !conf.Filter.SkipForks ||
+		conf.Filter.MinStars != 5 || len(conf.Filter.Languages) != 1 {
+		t.Fatalf("Invalid Filter conf: %#v", conf)
+	}
+	if conf.Daemon.Jitter != 60 ||
+		conf.Daemon.LockFile != "/tmp/geronimo.lock" ||
+		conf.Daemon.StatusFile != "/tmp/geronimo.json" ||
+		len(conf.Daemon.Schedules) != 2 ||
+		conf.Daemon.Schedules["commit"] != "02:30" {
+		t.Fatalf("Invalid Daemon conf: %#v", conf)
+	}
 }
@@ -0,0 +1,189 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// EnvPrefix is the prefix of the environment variables overriding the
+// configuration keys: GERONIMO_<SECTION>_<KEY>, e.g. GERONIMO_GITHUB_API_TOKEN.
+const EnvPrefix = "GERONIMO_"
+
+// redacted replaces the secrets in the printed configuration.
+const redacted = "********"
+
+// field is a key of the configuration.
+type field struct {
+	Key    string
+	Value  reflect.Value
+	Secret bool
+}
+
+// Env returns the environment variable overriding the key.
+func (f field) Env() string {
+	return EnvPrefix + strings.ToUpper(strings.Replace(f.Key, ".", "_", -1))
+}
+
+// tomlName returns the key of a struct field, as matched by the decoder.
+func tomlName(f reflect.StructField) string {
+	if name := f.Tag.Get("toml"); name != "" {
+		return name
+	}
+	return strings.ToLower(f.Name)
+}
+
+// fields returns the keys of the configuration, as section.key.
+func (c *Configuration) fields() []field {
+	var fields []field
+	sections := reflect.ValueOf(c).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		section := sections.Field(i)
+		name := tomlName(sections.Type().Field(i))
+		for j := 0; j < section.NumField(); j++ {
+			f := section.Type().Field(j)
+			fields = append(fields, field{
+				Key:    name + "." + tomlName(f),
+				Value:  section.Field(j),
+				Secret: f.Tag.Get("secret") == "true",
+			})
+		}
+	}
+	return fields
+}
+
+// Keys returns the keys of the configuration, as section.key.
+func (c *Configuration) Keys() []string {
+	var keys []string
+	for _, f := range c.fields() {
+		keys = append(keys, f.Key)
+	}
+	return keys
+}
+
+// Set overrides a key of the configuration. Lists are comma separated, and
+// maps are comma separated key=value pairs.
+func (c *Configuration) Set(key string, value string) error {
+	for _, f := range c.fields() {
+		if f.Key == key {
+			if err := setValue(f.Value, value); err != nil {
+				return fmt.Errorf("invalid %s: %s", key, err.Error())
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown configuration key %s", key)
+}
+
+func setValue(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		values := []string{}
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				values = append(values, s)
+			}
+		}
+		v.Set(reflect.ValueOf(values))
+	case reflect.Map:
+		values := map[string]string{}
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			pair := strings.SplitN(s, "=", 2)
+			if len(pair) != 2 {
+				return fmt.Errorf("%s isn't a key=value pair", s)
+			}
+			values[strings.TrimSpace(pair[0])] = strings.TrimSpace(pair[1])
+		}
+		v.Set(reflect.ValueOf(values))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// applyEnvironment overrides the keys set in the environment.
+func (c *Configuration) applyEnvironment() error {
+	for _, f := range c.fields() {
+		value, ok := os.LookupEnv(f.Env())
+		if !ok {
+			continue
+		}
+		if err := setValue(f.Value, value); err != nil {
+			return fmt.Errorf("invalid %s: %s", f.Env(), err.Error())
+		}
+	}
+	return nil
+}
+
+// HasEnvironment returns true if a key of the configuration is set in the
+// environment.
+func HasEnvironment() bool {
+	for _, f := range (&Configuration{}).fields() {
+		if _, ok := os.LookupEnv(f.Env()); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Environment returns the environment variables of the configuration keys.
+func Environment() []string {
+	var envs []string
+	for _, f := range (&Configuration{}).fields() {
+		envs = append(envs, f.Env())
+	}
+	sort.Strings(envs)
+	return envs
+}
+
+// Redacted returns a copy of the configuration without the secrets.
+func (c Configuration) Redacted() *Configuration {
+	for _, f := range c.fields() {
+		if f.Secret && f.Value.String() != "" {
+			f.Value.SetString(redacted)
+		}
+	}
+	return &c
+}
+
+// Write writes the configuration as TOML.
+func (c *Configuration) Write(w io.Writer) error {
+	return toml.NewEncoder(w).Encode(c)
+}
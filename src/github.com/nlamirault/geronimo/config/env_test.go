@@ -0,0 +1,106 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEnvironmentOverrides(t *testing.T) {
+	data := []byte(`
+[github]
+api_token = "azerty2468"
+user = "nlamirault"
+
+[elasticsearch]
+host = "localhost:9200"
+`)
+	configFile := createConfiguration(t, data)
+	defer os.RemoveAll(configFile.Name())
+	envs := map[string]string{
+		"GERONIMO_GITHUB_API_TOKEN":     "qwerty1357",
+		"GERONIMO_GITHUB_ORGANIZATIONS": "docker, portefaix",
+		"GERONIMO_ELASTICSEARCH_HOST":   "http://es:9200",
+		"GERONIMO_GITHUB_CACHE_SIZE":    "50",
+		"GERONIMO_SYNC_SNAPSHOTS":       "true",
+		"GERONIMO_DAEMON_SCHEDULES":     "issue=1h,commit=02:30",
+		"GERONIMO_NSQ_LOOKUPD":          "lookupd:4161",
+	}
+	for key, value := range envs {
+		os.Setenv(key, value)
+		defer os.Unsetenv(key)
+	}
+	if !HasEnvironment() {
+		t.Fatalf("Environment not found")
+	}
+	conf, err := Load(configFile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Github.APIToken != "qwerty1357" || conf.Github.User != "nlamirault" ||
+		conf.Github.CacheSize != 50 || len(conf.Github.Organizations) != 2 ||
+		conf.Github.Organizations[1] != "portefaix" {
+		t.Fatalf("Invalid Github conf: %#v", conf.Github)
+	}
+	if conf.ElasticSearch.Host != "http://es:9200" || !conf.Sync.Snapshots ||
+		conf.NSQ.Lookupd != "lookupd:4161" ||
+		conf.Daemon.Schedules["commit"] != "02:30" {
+		t.Fatalf("Invalid conf: %#v", conf)
+	}
+}
+
+func TestInvalidEnvironment(t *testing.T) {
+	os.Setenv("GERONIMO_SYNC_MAX_COMMITS", "many")
+	defer os.Unsetenv("GERONIMO_SYNC_MAX_COMMITS")
+	if _, err := Load(""); err == nil ||
+		!strings.Contains(err.Error(), "GERONIMO_SYNC_MAX_COMMITS") {
+		t.Fatalf("Invalid environment error: %v", err)
+	}
+}
+
+func TestSet(t *testing.T) {
+	conf := &Configuration{}
+	if err := conf.Set("storage.backend", "sqlite"); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Storage.Backend != "sqlite" {
+		t.Fatalf("Invalid storage conf: %#v", conf.Storage)
+	}
+	if err := conf.Set("storage.foo", "bar"); err == nil {
+		t.Fatalf("Unknown key set")
+	}
+	if err := conf.Set("daemon.schedules", "issue"); err == nil {
+		t.Fatalf("Invalid schedules set")
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	conf := &Configuration{}
+	conf.Github.APIToken = "azerty2468"
+	conf.Github.User = "nlamirault"
+	var b bytes.Buffer
+	if err := conf.Redacted().Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "azerty2468") || !strings.Contains(b.String(), "nlamirault") {
+		t.Fatalf("Invalid redacted configuration: %s", b.String())
+	}
+	if conf.Github.APIToken != "azerty2468" {
+		t.Fatalf("Configuration modified: %#v", conf.Github)
+	}
+}
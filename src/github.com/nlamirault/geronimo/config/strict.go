@@ -0,0 +1,118 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// keyLines returns the line of each key and table of a TOML document.
+func keyLines(data []byte) map[string]int {
+	lines := map[string]int{}
+	table := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "["):
+			table = strings.Trim(strings.SplitN(line, "#", 2)[0], "[] \t")
+			lines[table] = n
+		case strings.Contains(line, "="):
+			key := strings.Trim(strings.SplitN(line, "=", 2)[0], "\"' \t")
+			if table != "" {
+				key = table + "." + key
+			}
+			if _, ok := lines[key]; !ok {
+				lines[key] = n
+			}
+		}
+	}
+	return lines
+}
+
+// unknownKeys returns an error listing the keys of the document which weren't
+// decoded into the configuration, with their line and the closest known key.
+func unknownKeys(data []byte, md toml.MetaData) error {
+	undecoded := map[string]bool{}
+	for _, key := range md.Undecoded() {
+		undecoded[key.String()] = true
+	}
+	var known []string
+	for _, key := range (&Configuration{}).Keys() {
+		known = append(known, key, strings.SplitN(key, ".", 2)[0])
+	}
+	lines := keyLines(data)
+	var unknown []string
+	for _, key := range md.Undecoded() {
+		name := key.String()
+		// Report the unknown tables, not their keys
+		if len(key) > 1 && undecoded[key[:len(key)-1].String()] {
+			continue
+		}
+		msg := fmt.Sprintf("line %d: unknown key %s", lines[name], name)
+		if suggestion := closest(name, known); suggestion != "" {
+			msg += fmt.Sprintf(" (did you mean %s?)", suggestion)
+		}
+		unknown = append(unknown, msg)
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%s", strings.Join(unknown, "\n"))
+	}
+	return nil
+}
+
+// closest returns the known key nearest to key, if it looks like a typo.
+func closest(key string, known []string) string {
+	best, distance := "", 3
+	for _, k := range known {
+		if d := levenshtein(strings.ToLower(key), k); d < distance {
+			best, distance = k, d
+		}
+	}
+	return best
+}
+
+// levenshtein returns the edit distance between two strings.
+func levenshtein(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j-1] + cost
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
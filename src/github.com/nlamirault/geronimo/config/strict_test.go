@@ -0,0 +1,65 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestUnknownKeys(t *testing.T) {
+	data := []byte(`
+[github]
+usr = "nlamirault"
+api_token = "azerty2468"
+
+[daemon.schedules]
+issue = "1h"
+
+[foo]
+bar = 1
+`)
+	configFile := createConfiguration(t, data)
+	defer os.RemoveAll(configFile.Name())
+	_, err := Load(configFile.Name())
+	if err == nil {
+		t.Fatalf("Unknown keys loaded")
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, "line 3: unknown key github.usr (did you mean github.user?)") ||
+		!strings.Contains(msg, "line 9: unknown key foo") ||
+		strings.Contains(msg, "foo.bar") || strings.Contains(msg, "issue") {
+		t.Fatalf("Invalid unknown keys error: %s", msg)
+	}
+}
+
+func TestInvalidSyntax(t *testing.T) {
+	configFile := createConfiguration(t, []byte("[github]\nuser = nlamirault\n"))
+	defer os.RemoveAll(configFile.Name())
+	_, err := Load(configFile.Name())
+	if err == nil || !strings.Contains(err.Error(), configFile.Name()) {
+		t.Fatalf("Invalid syntax error: %v", err)
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	if d := levenshtein("lookupd", "lookup"); d != 1 {
+		t.Fatalf("Invalid distance: %d", d)
+	}
+	if d := levenshtein("", "abc"); d != 3 {
+		t.Fatalf("Invalid distance: %d", d)
+	}
+}
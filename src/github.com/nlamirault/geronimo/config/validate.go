@@ -0,0 +1,97 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// CommitsSinceLayout is the layout of the commits_since setting.
+const CommitsSinceLayout = "2006-01-02"
+
+// Validate returns the errors of the values of the configuration. The names
+// of the storage backends, layouts, filters and schedules are checked by
+// their users.
+func (c *Configuration) Validate() []error {
+	var errs []error
+	if c.Github.APIToken == "" {
+		errs = append(errs, fmt.Errorf("github: api_token is required, create one on https://github.com/settings/tokens"))
+	}
+	if len(c.Github.AllUsers())+len(c.Github.Organizations) == 0 {
+		errs = append(errs, fmt.Errorf("github: no user nor organization to synchronize, set users or organizations"))
+	}
+	if c.Github.CacheSize < 0 {
+		errs = append(errs, fmt.Errorf("github: negative cache_size"))
+	}
+	if c.Storage.Backend == "" || c.Storage.Backend == "elasticsearch" {
+		if err := validateURL(c.ElasticSearch.Host); err != nil {
+			errs = append(errs, fmt.Errorf("elasticsearch: invalid host: %s", err.Error()))
+		}
+	}
+	if c.NSQ.Lookupd != "" {
+		if err := validateHostPort(c.NSQ.Lookupd); err != nil {
+			errs = append(errs, fmt.Errorf("nsq: invalid lookupd: %s", err.Error()))
+		}
+	}
+	if c.Sync.CommitsSince != "" {
+		if _, err := time.Parse(CommitsSinceLayout, c.Sync.CommitsSince); err != nil {
+			errs = append(errs, fmt.Errorf("sync: invalid commits_since: %s", err.Error()))
+		}
+	}
+	if c.Sync.MaxCommits < 0 {
+		errs = append(errs, fmt.Errorf("sync: negative max_commits"))
+	}
+	if c.Sync.DeletedRetention < 0 {
+		errs = append(errs, fmt.Errorf("sync: negative deleted_retention"))
+	}
+	if c.Daemon.Jitter < 0 {
+		errs = append(errs, fmt.Errorf("daemon: negative jitter"))
+	}
+	return errs
+}
+
+// validateURL checks an URL is an absolute HTTP one.
+func validateURL(value string) error {
+	if value == "" {
+		return fmt.Errorf("empty URL, e.g. http://localhost:9200")
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("%s isn't an http(s)://host[:port] URL", value)
+	}
+	return nil
+}
+
+// validateHostPort checks an address is a host:port pair.
+func validateHostPort(value string) error {
+	host, port, err := net.SplitHostPort(value)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		return fmt.Errorf("%s has no host", value)
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return fmt.Errorf("%s has an invalid port", value)
+	}
+	return nil
+}
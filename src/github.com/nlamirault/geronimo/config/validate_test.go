@@ -0,0 +1,40 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	conf := &Configuration{}
+	conf.Sync.CommitsSince = "yesterday"
+	conf.Sync.MaxCommits = -1
+	conf.NSQ.Lookupd = "lookupd"
+	// The token, the owners, the Elasticsearch host, commits_since,
+	// max_commits and lookupd
+	if errs := conf.Validate(); len(errs) != 6 {
+		t.Fatalf("Invalid configuration errors: %v", errs)
+	}
+	conf.Github.APIToken = "azerty2468"
+	conf.Github.Organizations = []string{"geronimo-org"}
+	conf.ElasticSearch.Host = "https://localhost:9200"
+	conf.Sync.CommitsSince = "2015-12-01"
+	conf.Sync.MaxCommits = 0
+	conf.NSQ.Lookupd = "lookupd:4161"
+	if errs := conf.Validate(); len(errs) != 0 {
+		t.Fatalf("Invalid valid configuration: %v", errs)
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+
+	"github.com/nlamirault/geronimo/config"
+	gh "github.com/nlamirault/geronimo/providers/github"
+	"github.com/nlamirault/geronimo/storage"
+)
+
+// configurationTemplate is the configuration written by config init.
+const configurationTemplate = `# Geronimo configuration
+#
+# Every key can be overridden by a GERONIMO_<SECTION>_<KEY> environment
+# variable, e.g. GERONIMO_GITHUB_API_TOKEN, or by the -set section.key=value
+# flag. Run "geronimo config show" to print the effective configuration.
+
+[github]
+# Token of the GitHub API
+api_token = ""
+# Users and organizations to synchronize
+users = []
+organizations = []
+# Directory of the GitHub responses cache (default ~/.cache/geronimo)
+# cache_dir = ""
+# cache_size = 100
+
+[storage]
+# elasticsearch, sqlite (built with the sqlite tag) or memory
+backend = "elasticsearch"
+# type: one index per document type, repository: one index per repository
+layout = "repository"
+# index_prefix = ""
+
+[elasticsearch]
+host = "http://localhost:9200"
+
+[sqlite]
+# Database file (default ~/.local/share/geronimo/geronimo.db)
+# path = ""
+
+[sync]
+# commits_since = "2015-01-01"
+# max_commits = 0
+snapshots = true
+# Days the documents of deleted repositories are kept, 0 for ever
+deleted_retention = 0
+
+[filter]
+# include = []
+# exclude = []
+# skip_forks = false
+# skip_archived = false
+
+[daemon]
+# Maximum random delay in seconds added to each run
+jitter = 300
+
+[daemon.schedules]
+repository = "1h"
+issue = "1h"
+pull_request = "1h"
+star = "1h"
+commit = "02:00"
+contributor = "02:00"
+release = "6h"
+tag = "6h"
+`
+
+func configValidateCommand() *command {
+	cmd := newCommand("validate", "", "Check the configuration file.")
+	cmd.help = "The values are checked, then the connections to the storage backend,\n" +
+		"GitHub and NSQ are tested."
+	cmd.noValidate = true
+	offline := cmd.flags.Bool("offline", false, "Don't test the connections")
+	cmd.run = func(conf *config.Configuration, args []string) error {
+		filename, err := getConfigurationFile()
+		if err != nil {
+			if configurationNamed() {
+				return err
+			}
+			filename = "from the environment"
+		}
+		errs := validateConfiguration(conf)
+		if len(errs) == 0 && !*offline {
+			client := gh.NewClient(conf.Github.APIToken, gh.NewRateLimiter(), nil)
+			errs = checkConnections(conf, client)
+		}
+		if len(errs) == 0 {
+			fmt.Printf("Configuration %s is valid\n", filename)
+			return nil
+		}
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		}
+		return fmt.Errorf("invalid configuration %s: %d errors",
+			filename, len(errs))
+	}
+	return cmd
+}
+
+func configShowCommand() *command {
+	cmd := newCommand("show", "", "Print the effective configuration.")
+	cmd.help = "The file, the " + config.EnvPrefix + " environment variables and the -set\n" +
+		"flags are merged, and the secrets are redacted. The variables are:\n  " +
+		strings.Join(config.Environment(), "\n  ")
+	cmd.noValidate = true
+	cmd.run = func(conf *config.Configuration, args []string) error {
+		return conf.Redacted().Write(os.Stdout)
+	}
+	return cmd
+}
+
+func configInitCommand() *command {
+	cmd := newCommand("init", "", "Write a configuration file to edit.")
+	cmd.noConfig = true
+	output := cmd.flags.String("output", "",
+		"Configuration file to write (default the -config flag, $"+configEnv+
+			" or the user configuration file)")
+	force := cmd.flags.Bool("force", false, "Overwrite an existing file")
+	cmd.run = func(_ *config.Configuration, args []string) error {
+		path := *output
+		if path == "" {
+			path = defaultConfigurationFile()
+		}
+		return writeConfigurationTemplate(path, *force)
+	}
+	return cmd
+}
+
+// writeConfigurationTemplate writes the configuration template, unless the
+// file exists and force isn't set.
+func writeConfigurationTemplate(path string, force bool) error {
+	if _, err := os.Stat(path); err == nil && !force {
+		return fmt.Errorf("%s already exists, use -force to overwrite it", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("can't create directory: %s", err.Error())
+	}
+	// The file holds the GitHub token
+	if err := ioutil.WriteFile(path, []byte(configurationTemplate), 0600); err != nil {
+		return fmt.Errorf("can't write configuration: %s", err.Error())
+	}
+	fmt.Printf("Configuration written to %s\n", path)
+	return nil
+}
+
+// validateConfiguration returns the errors of the configuration: the
+// errors of its values, then of the names of the storage backend, layout,
+// filters and schedules.
+func validateConfiguration(conf *config.Configuration) []error {
+	errs := conf.Validate()
+	if name := conf.Storage.Backend; name != "" && !contains(storage.Backends(), name) {
+		errs = append(errs, fmt.Errorf("storage: unknown backend %s (available: %s)",
+			name, strings.Join(storage.Backends(), ", ")))
+	}
+	if _, err := storage.NewLayout(conf.Storage); err != nil {
+		errs = append(errs, fmt.Errorf("storage: %s", err.Error()))
+	}
+	if _, err := newRepositoryFilter(conf.Filter); err != nil {
+		errs = append(errs, fmt.Errorf("filter: %s", err.Error()))
+	}
+	for datatype, value := range conf.Daemon.Schedules {
+		if !validSyncType(datatype) {
+			errs = append(errs, fmt.Errorf("daemon: unknown data type %s", datatype))
+		} else if _, err := parseSchedule(value); err != nil {
+			errs = append(errs, fmt.Errorf("daemon: %s: %s", datatype, err.Error()))
+		}
+	}
+	return errs
+}
+
+// checkConnections returns the errors of the connections to the storage
+// backend, GitHub and NSQ.
+func checkConnections(conf *config.Configuration, client *github.Client) []error {
+	var errs []error
+	backend, err := openBackend(conf)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("storage: can't connect: %s", err.Error()))
+	} else {
+		closeBackend(backend)
+	}
+	if _, _, err := client.RateLimits(); err != nil {
+		errs = append(errs, fmt.Errorf("github: can't connect: %s", err.Error()))
+		return errs
+	}
+	for _, user := range conf.Github.AllUsers() {
+		if _, _, err := client.Users.Get(user); err != nil {
+			errs = append(errs, fmt.Errorf("github: can't retrieve user %s: %s",
+				user, err.Error()))
+		}
+	}
+	for _, org := range conf.Github.Organizations {
+		if _, _, err := client.Organizations.Get(org); err != nil {
+			errs = append(errs, fmt.Errorf("github: can't retrieve organization %s: %s",
+				org, err.Error()))
+		}
+	}
+	if conf.NSQ.Lookupd != "" {
+		httpClient := &http.Client{Timeout: 10 * time.Second}
+		resp, err := httpClient.Get("http://" + conf.NSQ.Lookupd + "/ping")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				err = fmt.Errorf("%s", resp.Status)
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("nsq: can't connect to lookupd: %s", err.Error()))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,119 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	"github.com/nlamirault/geronimo/config"
+)
+
+func TestConfigurationTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geronimo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "geronimo.toml")
+	if err := writeConfigurationTemplate(path, false); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Fatalf("Invalid configuration mode: %v", info.Mode())
+	}
+	if err := writeConfigurationTemplate(path, false); err == nil {
+		t.Fatalf("Configuration overwritten")
+	}
+	if err := writeConfigurationTemplate(path, true); err != nil {
+		t.Fatal(err)
+	}
+	conf, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the token and the users and organizations are left to fill
+	errs := validateConfiguration(conf)
+	if len(errs) != 2 {
+		t.Fatalf("Invalid configuration template: %v", errs)
+	}
+}
+
+func TestValidateConfiguration(t *testing.T) {
+	conf := &config.Configuration{}
+	conf.Github.Users = []string{"nlamirault"}
+	conf.Storage.Backend = "foo"
+	conf.Storage.Layout = "bar"
+	conf.Sync.CommitsSince = "yesterday"
+	conf.Sync.DeletedRetention = -1
+	conf.Daemon.Schedules = map[string]string{"issue": "1s", "foo": "1h"}
+	errs := validateConfiguration(conf)
+	if len(errs) != 7 {
+		t.Fatalf("Invalid configuration errors: %v", errs)
+	}
+}
+
+func TestValidateAddresses(t *testing.T) {
+	conf := &config.Configuration{}
+	conf.Github.APIToken = "azerty2468"
+	conf.Github.Users = []string{"nlamirault"}
+	conf.ElasticSearch.Host = "localhost:9200"
+	conf.NSQ.Lookupd = "lookupd"
+	errs := validateConfiguration(conf)
+	if len(errs) != 2 {
+		t.Fatalf("Invalid address errors: %v", errs)
+	}
+	conf.ElasticSearch.Host = "http://localhost:9200"
+	conf.NSQ.Lookupd = "lookupd:4161"
+	if errs := validateConfiguration(conf); len(errs) != 0 {
+		t.Fatalf("Invalid valid addresses: %v", errs)
+	}
+}
+
+func TestCheckConnections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rate_limit":
+			fmt.Fprint(w, `{"resources": {"core": {"limit": 5000, "remaining": 5000}}}`)
+		case "/users/nlamirault", "/ping":
+			fmt.Fprint(w, `{"login": "nlamirault"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Not Found"}`)
+		}
+	}))
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	conf := &config.Configuration{}
+	conf.Storage.Backend = "memory"
+	conf.Github.Users = []string{"nlamirault"}
+	conf.Github.Organizations = []string{"foo"}
+	conf.NSQ.Lookupd = strings.TrimPrefix(server.URL, "http://")
+	errs := checkConnections(conf, client)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "organization foo") {
+		t.Fatalf("Invalid connection errors: %v", errs)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+
+	"github.com/nlamirault/geronimo/storage"
+)
+
+// retrieveRepositoryContributors retrieves the contributors of a repository.
+func retrieveRepositoryContributors(client *github.Client, repo *github.Repository) ([]github.Contributor, error) {
+	var contributors []github.Contributor
+	for page := options.From/options.PerPage + 1; page != 0; {
+		c, resp, err := client.Repositories.ListContributors(
+			userLogin(repo.Owner),
+			*repo.Name,
+			&github.ListContributorsOptions{
+				ListOptions: github.ListOptions{
+					PerPage: options.PerPage,
+					Page:    page,
+				}})
+		if err == io.EOF {
+			// An empty repository has no contributors: GitHub answers
+			// without content
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		contributors = append(contributors, c...)
+		page = nextPage(resp)
+	}
+	log.Printf("[DEBUG] Repository %s: %d contributors",
+		*repo.Name, len(contributors))
+	return contributors, nil
+}
+
+// newContributors creates the contributors of a repository. First and last
+// contribution dates are computed from the commits statistics of the authors.
+func newContributors(repo *github.Repository, contributors []github.Contributor, authors map[string]*storage.CommitAuthor) []storage.Contributor {
+	var data []storage.Contributor
+	for _, contributor := range contributors {
+		c := storage.Contributor{
+			Repository:    *repo.Name,
+			Login:         stringValue(contributor.Login),
+			Contributions: intValue(contributor.Contributions),
+		}
+		if author, ok := authors[c.Login]; ok {
+			c.First = author.First
+			c.Last = author.Last
+		}
+		data = append(data, c)
+	}
+	return data
+}
+
+func saveContributors(backend storage.Backend, owner string, repo *github.Repository, contributors []storage.Contributor) error {
+	index := options.Layout.Index("contributor", owner, *repo.Name)
+	for _, contributor := range contributors {
+		contributor.Owner = owner
+		err := backend.Upsert(index, "contributor",
+			options.Layout.ID(owner, *repo.Name, contributor.Login), contributor)
+		if err != nil {
+			return fmt.Errorf("can't store contributor %s: %s",
+				contributor.Login, err.Error())
+		}
+	}
+	log.Printf("[INFO] Indexed %d contributors of %s to index %s",
+		len(contributors), *repo.Name, index)
+	return nil
+}
+
+// contributorsAggregator aggregates the contributors of all the repositories.
+// It is safe for concurrent use by the indexers.
+type contributorsAggregator struct {
+	mu           sync.Mutex
+	contributors map[string]*storage.UserContributor
+}
+
+func newContributorsAggregator() *contributorsAggregator {
+	return &contributorsAggregator{
+		contributors: map[string]*storage.UserContributor{},
+	}
+}
+
+// Add adds the contributors of a repository.
+func (a *contributorsAggregator) Add(contributors []storage.Contributor) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, contributor := range contributors {
+		c, ok := a.contributors[contributor.Login]
+		if !ok {
+			c = &storage.UserContributor{
+				Login:        contributor.Login,
+				Repositories: []string{},
+			}
+			a.contributors[contributor.Login] = c
+		}
+		c.Contributions += contributor.Contributions
+		c.Repositories = append(c.Repositories, contributor.Repository)
+		sort.Strings(c.Repositories)
+		c.First = minTime(c.First, contributor.First)
+		c.Last = maxTime(c.Last, contributor.Last)
+	}
+}
+
+// Contributors returns the aggregated contributors sorted by login.
+func (a *contributorsAggregator) Contributors() []*storage.UserContributor {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var logins []string
+	for login := range a.contributors {
+		logins = append(logins, login)
+	}
+	sort.Strings(logins)
+	var contributors []*storage.UserContributor
+	for _, login := range logins {
+		contributors = append(contributors, a.contributors[login])
+	}
+	return contributors
+}
+
+// storedUserContributors aggregates the contributors stored for the
+// repositories of an owner, so the totals don't depend on the repositories
+// synchronized by this run.
+func storedUserContributors(backend storage.Backend, owner string) ([]*storage.UserContributor, error) {
+	repos, err := storedRepositories(backend, owner)
+	if err != nil {
+		return nil, fmt.Errorf("can't retrieve repositories: %s", err.Error())
+	}
+	aggregator := newContributorsAggregator()
+	for _, repo := range repos {
+		if repo.Deleted != nil {
+			continue
+		}
+		documents, err := backend.Query(
+			options.Layout.Index("contributor", owner, repo.Name), "contributor",
+			options.Layout.Terms("contributor", owner, repo.Name))
+		if err != nil {
+			return nil, fmt.Errorf("can't retrieve contributors of %s: %s",
+				repo.Name, err.Error())
+		}
+		var contributors []storage.Contributor
+		for _, document := range documents {
+			var contributor storage.Contributor
+			if err := json.Unmarshal(*document, &contributor); err != nil {
+				return nil, err
+			}
+			contributors = append(contributors, contributor)
+		}
+		aggregator.Add(contributors)
+	}
+	return aggregator.Contributors(), nil
+}
+
+// indexingUserContributors stores the contributors aggregated across the
+// stored repositories of an owner.
+func indexingUserContributors(backend storage.Backend, owner string) error {
+	contributors, err := storedUserContributors(backend, owner)
+	if err != nil {
+		return err
+	}
+	index := options.Layout.Index("user_contributor", owner, "")
+	if err := backend.CreateNamespace(index); err != nil {
+		return fmt.Errorf("can't create index: %s", err.Error())
+	}
+	for _, contributor := range contributors {
+		contributor.Owner = owner
+		err := backend.Upsert(index, "user_contributor",
+			options.Layout.ID(owner, "", contributor.Login), contributor)
+		if err != nil {
+			return fmt.Errorf("can't store contributor %s: %s",
+				contributor.Login, err.Error())
+		}
+	}
+	log.Printf("[INFO] Indexed %d contributors to index %s",
+		len(contributors), index)
+	return nil
+}
+
+func minTime(a *time.Time, b *time.Time) *time.Time {
+	if a == nil || (b != nil && b.Before(*a)) {
+		return b
+	}
+	return a
+}
+
+func maxTime(a *time.Time, b *time.Time) *time.Time {
+	if a == nil || (b != nil && b.After(*a)) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,138 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+
+	"github.com/nlamirault/geronimo/storage"
+)
+
+func TestNewContributors(t *testing.T) {
+	date := time.Date(2015, 12, 1, 0, 0, 0, 0, time.UTC)
+	repo := &github.Repository{Name: github.String("geronimo")}
+	contributors := []github.Contributor{
+		github.Contributor{
+			Login:         github.String("nlamirault"),
+			Contributions: github.Int(12),
+		},
+	}
+	authors := map[string]*storage.CommitAuthor{}
+	addCommitAuthor(authors, newCommit(repo, &github.RepositoryCommit{
+		SHA:    github.String("abcdef"),
+		Author: &github.User{Login: github.String("nlamirault")},
+		Commit: &github.Commit{
+			Author: &github.CommitAuthor{Date: &date},
+		},
+	}))
+	data := newContributors(repo, contributors, authors)
+	if len(data) != 1 || data[0].Login != "nlamirault" ||
+		data[0].Contributions != 12 || data[0].Repository != "geronimo" {
+		t.Fatalf("Invalid contributors: %#v", data)
+	}
+	if !data[0].First.Equal(date) || !data[0].Last.Equal(date) {
+		t.Fatalf("Invalid contributor dates: %#v", data[0])
+	}
+}
+
+func TestContributorsAggregator(t *testing.T) {
+	first := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	last := time.Date(2015, 12, 1, 0, 0, 0, 0, time.UTC)
+	aggregator := newContributorsAggregator()
+	aggregator.Add([]storage.Contributor{
+		storage.Contributor{
+			Repository: "geronimo", Login: "nlamirault",
+			Contributions: 10, First: &last, Last: &last,
+		},
+	})
+	aggregator.Add([]storage.Contributor{
+		storage.Contributor{
+			Repository: "aneto", Login: "nlamirault",
+			Contributions: 5, First: &first, Last: &first,
+		},
+		storage.Contributor{
+			Repository: "aneto", Login: "foo", Contributions: 1,
+		},
+	})
+	contributors := aggregator.Contributors()
+	if len(contributors) != 2 || contributors[0].Login != "foo" {
+		t.Fatalf("Invalid contributors: %v", contributors)
+	}
+	c := contributors[1]
+	if c.Contributions != 15 || len(c.Repositories) != 2 ||
+		c.Repositories[0] != "aneto" || c.Repositories[1] != "geronimo" {
+		t.Fatalf("Invalid contributor: %#v", c)
+	}
+	if !c.First.Equal(first) || !c.Last.Equal(last) {
+		t.Fatalf("Invalid contributor dates: %#v", c)
+	}
+}
+
+func TestRetrieveEmptyRepositoryContributors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	options.From = DefaultFrom
+	options.PerPage = 2
+	repo := &github.Repository{
+		Name:  github.String("empty"),
+		Owner: &github.User{Login: github.String("nlamirault")},
+	}
+	contributors, err := retrieveRepositoryContributors(client, repo)
+	if err != nil || len(contributors) != 0 {
+		t.Fatalf("Invalid empty repository contributors: %v %v", contributors, err)
+	}
+}
+
+func TestIndexingUserContributors(t *testing.T) {
+	options.Layout = &storage.Layout{Strategy: storage.LayoutType}
+	deleted := time.Date(2015, 12, 1, 0, 0, 0, 0, time.UTC)
+	backend := storage.NewMemory()
+	for _, repo := range []storage.Repository{
+		{Owner: "nlamirault", ID: 1, Name: "geronimo"},
+		{Owner: "nlamirault", ID: 2, Name: "abraracourcix"},
+		{Owner: "nlamirault", ID: 3, Name: "gone", Deleted: &deleted},
+	} {
+		storeTestRepository(t, backend, repo)
+		err := backend.Upsert("geronimo_contributor", "contributor",
+			"nlamirault/"+repo.Name+"/nlamirault",
+			storage.Contributor{
+				Owner: "nlamirault", Repository: repo.Name,
+				Login: "nlamirault", Contributions: 10,
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The repositories not synchronized by this run are counted too
+	if err := indexingUserContributors(backend, "nlamirault"); err != nil {
+		t.Fatal(err)
+	}
+	var contributor storage.UserContributor
+	found, _ := backend.Get("geronimo_user_contributor", "user_contributor",
+		"nlamirault/nlamirault", &contributor)
+	if !found || contributor.Contributions != 20 || len(contributor.Repositories) != 2 {
+		t.Fatalf("Invalid user contributor: %t %#v", found, contributor)
+	}
+}
@@ -0,0 +1,369 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nlamirault/geronimo/config"
+	"github.com/nlamirault/geronimo/storage"
+)
+
+// defaultSchedule is the schedule of the data types when none is configured.
+const defaultSchedule = "1h"
+
+// errInterrupted is the error of a synchronization stopped by a signal.
+var errInterrupted = errors.New("interrupted")
+
+// schedule is the schedule of the synchronization of a data type: at a fixed
+// interval, or daily at a time of day.
+type schedule struct {
+	interval time.Duration
+	hour     int
+	minute   int
+}
+
+// parseSchedule parses a duration (1h30m) or a time of day (02:30).
+func parseSchedule(s string) (*schedule, error) {
+	if t, err := time.Parse("15:04", s); err == nil {
+		return &schedule{hour: t.Hour(), minute: t.Minute()}, nil
+	}
+	interval, err := time.ParseDuration(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: not a duration nor a time of day", s)
+	}
+	if interval < time.Minute {
+		return nil, fmt.Errorf("invalid schedule %q: less than a minute", s)
+	}
+	return &schedule{interval: interval}, nil
+}
+
+// Next returns the time of the first run after t.
+func (s *schedule) Next(t time.Time) time.Time {
+	if s.interval > 0 {
+		return t.Add(s.interval)
+	}
+	next := time.Date(t.Year(), t.Month(), t.Day(), s.hour, s.minute, 0, 0, t.Location())
+	if !next.After(t) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// typeStatus is the status of the synchronization of a data type.
+type typeStatus struct {
+	Schedule  string     `json:"schedule"`
+	Running   bool       `json:"running"`
+	LastStart *time.Time `json:"last_start,omitempty"`
+	LastEnd   *time.Time `json:"last_end,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+	Next      time.Time  `json:"next"`
+}
+
+// daemonStatus is the status of the daemon, written into the status file.
+type daemonStatus struct {
+	PID     int                    `json:"pid"`
+	Started time.Time              `json:"started"`
+	Types   map[string]*typeStatus `json:"types"`
+}
+
+// statusFile returns the path of the status file.
+func statusFile(conf *config.Configuration) string {
+	if conf.Daemon.StatusFile != "" {
+		return conf.Daemon.StatusFile
+	}
+	return filepath.Join(os.Getenv("HOME"), ".local", "share", "geronimo", "status.json")
+}
+
+// loadStatus reads the status file. It returns nil if the file doesn't exist.
+func loadStatus(path string) (*daemonStatus, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var status daemonStatus
+	if err := json.Unmarshal(b, &status); err != nil {
+		return nil, fmt.Errorf("invalid status file %s: %s", path, err.Error())
+	}
+	return &status, nil
+}
+
+// save writes the status file atomically.
+func (s *daemonStatus) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// print writes the status as a table.
+func (s *daemonStatus) print(w io.Writer) {
+	fmt.Fprintf(w, "Daemon %d started at %s\n\n", s.PID, s.Started.Format(time.RFC3339))
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "TYPE\tSCHEDULE\tLAST RUN\tRESULT\tNEXT RUN")
+	var types []string
+	for datatype := range s.Types {
+		types = append(types, datatype)
+	}
+	sort.Strings(types)
+	for _, datatype := range types {
+		t := s.Types[datatype]
+		last, result := "never", ""
+		if t.LastStart != nil {
+			last = t.LastStart.Format(time.RFC3339)
+		}
+		switch {
+		case t.Running:
+			result = "running"
+		case t.LastError != "":
+			result = "error: " + t.LastError
+		case t.LastEnd != nil:
+			result = fmt.Sprintf("ok (%s)", t.LastEnd.Sub(*t.LastStart).Round(time.Second))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			datatype, t.Schedule, last, result, t.Next.Format(time.RFC3339))
+	}
+	tw.Flush()
+}
+
+// daemon synchronizes the data types according to their schedules.
+type daemon struct {
+	conf       *config.Configuration
+	backend    storage.Backend
+	schedules  map[string]*schedule
+	jitter     time.Duration
+	statusFile string
+	status     *daemonStatus
+}
+
+// newDaemon creates a daemon from the configuration. The runs of the data
+// types synchronized by a previous daemon are scheduled from their last run,
+// the others run immediately.
+func newDaemon(conf *config.Configuration, backend storage.Backend) (*daemon, error) {
+	d := &daemon{
+		conf:       conf,
+		backend:    backend,
+		schedules:  map[string]*schedule{},
+		jitter:     time.Duration(conf.Daemon.Jitter) * time.Second,
+		statusFile: statusFile(conf),
+		status: &daemonStatus{
+			PID:     os.Getpid(),
+			Started: time.Now(),
+			Types:   map[string]*typeStatus{},
+		},
+	}
+	schedules := conf.Daemon.Schedules
+	if len(schedules) == 0 {
+		schedules = map[string]string{}
+		for _, datatype := range syncTypes {
+			schedules[datatype] = defaultSchedule
+		}
+	}
+	previous, err := loadStatus(d.statusFile)
+	if err != nil {
+		return nil, err
+	}
+	for datatype, value := range schedules {
+		if !validSyncType(datatype) {
+			return nil, fmt.Errorf("unknown data type %s", datatype)
+		}
+		s, err := parseSchedule(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", datatype, err.Error())
+		}
+		d.schedules[datatype] = s
+		status := &typeStatus{Schedule: value, Next: d.status.Started}
+		if previous != nil {
+			if last, ok := previous.Types[datatype]; ok && last.LastStart != nil {
+				status.LastStart = last.LastStart
+				status.LastEnd = last.LastEnd
+				status.LastError = last.LastError
+				status.Next = d.delay(s.Next(*last.LastStart))
+			}
+		}
+		d.status.Types[datatype] = status
+	}
+	return d, nil
+}
+
+// delay adds a random delay up to the jitter to a time.
+func (d *daemon) delay(t time.Time) time.Time {
+	if d.jitter <= 0 {
+		return t
+	}
+	return t.Add(time.Duration(rand.Int63n(int64(d.jitter))))
+}
+
+// next returns the time of the next run.
+func (d *daemon) next() time.Time {
+	var next time.Time
+	for _, status := range d.status.Types {
+		if next.IsZero() || status.Next.Before(next) {
+			next = status.Next
+		}
+	}
+	return next
+}
+
+// due returns the sorted data types to synchronize at a time.
+func (d *daemon) due(now time.Time) []string {
+	var types []string
+	for datatype, status := range d.status.Types {
+		if !status.Next.After(now) {
+			types = append(types, datatype)
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+func (d *daemon) saveStatus() {
+	if err := d.status.save(d.statusFile); err != nil {
+		log.Printf("[ERROR] Can't write status: %s", err.Error())
+	}
+}
+
+// run synchronizes data types, unless another synchronization is running,
+// and schedules their next run.
+func (d *daemon) run(types []string, stop <-chan struct{}) {
+	start := time.Now()
+	lock, err := acquireLock(lockFile(d.conf))
+	if err == nil {
+		for _, datatype := range types {
+			status := d.status.Types[datatype]
+			status.Running = true
+			status.LastStart = &start
+			status.LastEnd = nil
+		}
+		d.saveStatus()
+		log.Printf("[INFO] Synchronize %v", types)
+		err = synchronize(d.conf, d.backend, types, stop)
+		if err == nil && stopped() {
+			err = errInterrupted
+		}
+		lock.Release()
+	}
+	end := time.Now()
+	for _, datatype := range types {
+		status := d.status.Types[datatype]
+		if err == errLocked {
+			// Retry later without losing the last run
+			status.Next = d.delay(d.schedules[datatype].Next(end))
+			continue
+		}
+		status.Running = false
+		status.LastEnd = &end
+		status.LastError = ""
+		if err != nil {
+			status.LastError = err.Error()
+		}
+		status.Next = d.delay(d.schedules[datatype].Next(end))
+	}
+	if err != nil {
+		log.Printf("[ERROR] Synchronization of %v failed: %s", types, err.Error())
+	}
+	d.saveStatus()
+}
+
+// Run synchronizes the data types when they are due, until stop is closed.
+func (d *daemon) Run(stop <-chan struct{}) {
+	for {
+		next := d.next()
+		d.saveStatus()
+		log.Printf("[INFO] Next synchronization at %s", next.Format(time.RFC3339))
+		timer := time.NewTimer(next.Sub(time.Now()))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		d.run(d.due(time.Now()), stop)
+		select {
+		case <-stop:
+			return
+		default:
+		}
+	}
+}
+
+// notifyStop returns a channel closed on SIGINT or SIGTERM, to finish the
+// documents in progress. A second signal exits immediately.
+func notifyStop() <-chan struct{} {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	stop := make(chan struct{})
+	go func() {
+		sig := <-signals
+		log.Printf("[INFO] %s received, finishing the documents in progress", sig)
+		close(stop)
+		sig = <-signals
+		log.Printf("[WARN] %s received, exiting", sig)
+		os.Exit(1)
+	}()
+	return stop
+}
+
+// runDaemon runs the synchronizations according to the schedules until
+// SIGINT or SIGTERM.
+func runDaemon(conf *config.Configuration, backend storage.Backend) error {
+	rand.Seed(time.Now().UnixNano())
+	d, err := newDaemon(conf, backend)
+	if err != nil {
+		return err
+	}
+	log.Printf("[INFO] Daemon started, status in %s", d.statusFile)
+	d.Run(notifyStop())
+	log.Printf("[INFO] Daemon stopped")
+	return nil
+}
+
+// printStatus prints the status of the daemon.
+func printStatus(conf *config.Configuration, w io.Writer) error {
+	path := statusFile(conf)
+	status, err := loadStatus(path)
+	if err != nil {
+		return err
+	}
+	if status == nil {
+		return fmt.Errorf("no status in %s: the daemon never ran", path)
+	}
+	status.print(w)
+	return nil
+}
@@ -0,0 +1,117 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlamirault/geronimo/config"
+)
+
+func TestParseSchedule(t *testing.T) {
+	now := time.Date(2015, 12, 10, 18, 0, 0, 0, time.UTC)
+	s, err := parseSchedule("1h30m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := s.Next(now); !next.Equal(now.Add(90 * time.Minute)) {
+		t.Fatalf("Invalid interval schedule: %s", next)
+	}
+	s, err = parseSchedule("02:30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := s.Next(now); !next.Equal(time.Date(2015, 12, 11, 2, 30, 0, 0, time.UTC)) {
+		t.Fatalf("Invalid daily schedule: %s", next)
+	}
+	if next := s.Next(now.Add(-16 * time.Hour)); !next.Equal(time.Date(2015, 12, 10, 2, 30, 0, 0, time.UTC)) {
+		t.Fatalf("Invalid daily schedule on the same day: %s", next)
+	}
+	for _, invalid := range []string{"", "hourly", "10s", "25:00"} {
+		if _, err := parseSchedule(invalid); err == nil {
+			t.Fatalf("Invalid schedule accepted: %q", invalid)
+		}
+	}
+}
+
+func TestDaemonSchedules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geronimo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	conf := &config.Configuration{
+		Daemon: config.DaemonConfig{
+			Schedules: map[string]string{
+				"repository": "1h",
+				"commit":     "02:30",
+			},
+			StatusFile: filepath.Join(dir, "status.json"),
+		},
+	}
+	d, err := newDaemon(conf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without previous run, everything runs immediately
+	if types := d.due(d.status.Started); len(types) != 2 {
+		t.Fatalf("Invalid due types: %v", types)
+	}
+
+	last := d.status.Started.Add(-30 * time.Minute)
+	d.status.Types["repository"].LastStart = &last
+	d.status.Types["repository"].LastEnd = &last
+	d.saveStatus()
+	d, err = newDaemon(conf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := d.status.Types["repository"].Next; !next.Equal(last.Add(time.Hour)) {
+		t.Fatalf("Invalid next run from the previous run: %s", next)
+	}
+	if types := d.due(d.status.Started); len(types) != 1 || types[0] != "commit" {
+		t.Fatalf("Invalid due types: %v", types)
+	}
+	if next := d.next(); !next.Equal(d.status.Started) {
+		t.Fatalf("Invalid next run: %s", next)
+	}
+
+	var b bytes.Buffer
+	d.status.print(&b)
+	if !strings.Contains(b.String(), "commit") || !strings.Contains(b.String(), "never") {
+		t.Fatalf("Invalid status: %s", b.String())
+	}
+
+	conf.Daemon.Schedules["foo"] = "1h"
+	if _, err := newDaemon(conf, nil); err == nil {
+		t.Fatalf("Invalid unknown data type accepted")
+	}
+}
+
+func TestDaemonJitter(t *testing.T) {
+	d := &daemon{jitter: time.Minute}
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		if delayed := d.delay(now); delayed.Before(now) || !delayed.Before(now.Add(time.Minute)) {
+			t.Fatalf("Invalid jitter: %s", delayed.Sub(now))
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nlamirault/geronimo/config"
+	"github.com/nlamirault/geronimo/storage"
+)
+
+// exportOwnerTypes are the document types of an owner which can be exported.
+var exportOwnerTypes = []string{"repository", "user_contributor"}
+
+// exportedDocument is a line of an export.
+type exportedDocument struct {
+	Type     string           `json:"type"`
+	Document *json.RawMessage `json:"document"`
+}
+
+func exportCommand() *command {
+	cmd := newCommand("export", "", "Export the stored documents as JSON lines.")
+	cmd.help = "Each line holds the type of a document of the users and\n" +
+		"organizations, and the document."
+	owners := cmd.flags.String("owners", "",
+		"Comma separated users and organizations (default all the configured ones)")
+	types := cmd.flags.String("types", "",
+		"Comma separated document types (default all): "+
+			strings.Join(exportTypes(), ", "))
+	output := cmd.flags.String("output", "", "Output file (default standard output)")
+	cmd.run = func(conf *config.Configuration, args []string) error {
+		if err := loadLayout(conf); err != nil {
+			return err
+		}
+		logins := configuredOwners(conf)
+		if *owners != "" {
+			logins = strings.Split(*owners, ",")
+		}
+		datatypes := exportTypes()
+		if *types != "" {
+			datatypes = strings.Split(*types, ",")
+		}
+		backend, err := openBackend(conf)
+		if err != nil {
+			return fmt.Errorf("can't open storage: %s", err.Error())
+		}
+		defer closeBackend(backend)
+		w := io.Writer(os.Stdout)
+		if *output != "" {
+			f, err := os.Create(*output)
+			if err != nil {
+				return fmt.Errorf("can't create export: %s", err.Error())
+			}
+			defer f.Close()
+			w = f
+		}
+		return exportDocuments(backend, logins, datatypes, w)
+	}
+	return cmd
+}
+
+// exportTypes returns the document types which can be exported.
+func exportTypes() []string {
+	return append(append([]string{}, exportOwnerTypes...),
+		storage.RepositoryTypes()...)
+}
+
+// exportDocuments writes the documents of types stored for owners.
+func exportDocuments(backend storage.Backend, owners []string, types []string, w io.Writer) error {
+	for _, datatype := range types {
+		if !contains(exportTypes(), datatype) {
+			return fmt.Errorf("can't export %s documents", datatype)
+		}
+	}
+	encoder := json.NewEncoder(w)
+	write := func(datatype string, documents []*json.RawMessage) error {
+		for _, document := range documents {
+			err := encoder.Encode(exportedDocument{Type: datatype, Document: document})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, owner := range owners {
+		owner = strings.ToLower(owner)
+		repos, err := storedRepositories(backend, owner)
+		if err != nil {
+			return fmt.Errorf("can't retrieve repositories of %s: %s",
+				owner, err.Error())
+		}
+		for _, datatype := range types {
+			if !contains(exportOwnerTypes, datatype) {
+				continue
+			}
+			documents, err := backend.Query(
+				options.Layout.Index(datatype, owner, ""), datatype,
+				map[string]interface{}{"owner": owner})
+			if err != nil {
+				return err
+			}
+			if err := write(datatype, documents); err != nil {
+				return err
+			}
+		}
+		for _, repo := range repos {
+			for _, datatype := range types {
+				if contains(exportOwnerTypes, datatype) {
+					continue
+				}
+				documents, err := backend.Query(
+					options.Layout.Index(datatype, owner, repo.Name), datatype,
+					options.Layout.Terms(datatype, owner, repo.Name))
+				if err != nil {
+					return err
+				}
+				if err := write(datatype, documents); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/nlamirault/geronimo/storage"
+)
+
+func TestExportDocuments(t *testing.T) {
+	options.Layout = &storage.Layout{Strategy: storage.LayoutType}
+	backend := storage.NewMemory()
+	storeTestRepository(t, backend, storage.Repository{Owner: "nlamirault", ID: 1, Name: "geronimo"})
+
+	var b bytes.Buffer
+	err := exportDocuments(backend, []string{"nlamirault"},
+		[]string{"repository", "issue"}, &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Invalid export: %s", b.String())
+	}
+	var issue struct {
+		Type     string        `json:"type"`
+		Document storage.Issue `json:"document"`
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &issue); err != nil {
+		t.Fatal(err)
+	}
+	if issue.Type != "issue" || issue.Document.Repository != "geronimo" {
+		t.Fatalf("Invalid exported issue: %#v", issue)
+	}
+}
+
+func TestExportUnknownType(t *testing.T) {
+	var b bytes.Buffer
+	err := exportDocuments(storage.NewMemory(), []string{"nlamirault"},
+		[]string{"foo"}, &b)
+	if err == nil {
+		t.Fatalf("Unknown type exported")
+	}
+}
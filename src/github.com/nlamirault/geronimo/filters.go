@@ -0,0 +1,169 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/github"
+
+	"github.com/nlamirault/geronimo/config"
+	gh "github.com/nlamirault/geronimo/providers/github"
+)
+
+// namePattern matches the name of a repository with a glob or, if enclosed in
+// slashes, a regular expression. A pattern containing a slash matches the
+// full name of the repository.
+type namePattern struct {
+	pattern string
+	full    bool
+	regexp  *regexp.Regexp
+}
+
+func newNamePattern(pattern string) (*namePattern, error) {
+	p := &namePattern{pattern: pattern}
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %s", pattern, err.Error())
+		}
+		p.regexp = re
+		p.full = strings.Contains(re.String(), "/")
+		return p, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %s: %s", pattern, err.Error())
+	}
+	p.full = strings.Contains(pattern, "/")
+	return p, nil
+}
+
+// Match checks if the pattern matches a repository.
+func (p *namePattern) Match(repo *gh.Repository) bool {
+	name := stringValue(repo.Name)
+	if p.full {
+		name = stringValue(repo.FullName)
+	}
+	if p.regexp != nil {
+		return p.regexp.MatchString(name)
+	}
+	matched, _ := path.Match(p.pattern, name)
+	return matched
+}
+
+// repositoryFilter selects the repositories to synchronize.
+type repositoryFilter struct {
+	conf    config.FilterConfig
+	include []*namePattern
+	exclude []*namePattern
+}
+
+func newRepositoryFilter(conf config.FilterConfig) (*repositoryFilter, error) {
+	filter := &repositoryFilter{conf: conf}
+	for _, pattern := range conf.Include {
+		p, err := newNamePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		filter.include = append(filter.include, p)
+	}
+	for _, pattern := range conf.Exclude {
+		p, err := newNamePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		filter.exclude = append(filter.exclude, p)
+	}
+	return filter, nil
+}
+
+// Match checks if a repository must be synchronized. Otherwise, it returns
+// the reason why the repository is excluded.
+func (f *repositoryFilter) Match(repo *gh.Repository) (bool, string) {
+	if len(f.include) > 0 && !matchAny(f.include, repo) {
+		return false, "not included"
+	}
+	if matchAny(f.exclude, repo) {
+		return false, "excluded"
+	}
+	if f.conf.SkipForks && repo.Fork != nil && *repo.Fork {
+		return false, "fork"
+	}
+	if f.conf.SkipArchived && repo.Archived != nil && *repo.Archived {
+		return false, "archived"
+	}
+	if f.conf.SkipPrivate && repo.Private != nil && *repo.Private {
+		return false, "private"
+	}
+	if stars := intValue(repo.StargazersCount); stars < f.conf.MinStars {
+		return false, fmt.Sprintf("%d stars", stars)
+	}
+	if len(f.conf.Languages) > 0 && !containsFold(f.conf.Languages, stringValue(repo.Language)) {
+		return false, fmt.Sprintf("language %s", stringValue(repo.Language))
+	}
+	if len(f.conf.Topics) > 0 {
+		found := false
+		for _, topic := range repo.Topics {
+			if containsFold(f.conf.Topics, topic) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, "topics"
+		}
+	}
+	return true, ""
+}
+
+// filterRepositories returns the repositories selected by options.Filter.
+func filterRepositories(repos []gh.Repository) []github.Repository {
+	var selected []github.Repository
+	for i := range repos {
+		if options.Filter != nil {
+			if ok, reason := options.Filter.Match(&repos[i]); !ok {
+				log.Printf("[DEBUG] Skip repository %s: %s",
+					stringValue(repos[i].FullName), reason)
+				continue
+			}
+		}
+		selected = append(selected, repos[i].Repository)
+	}
+	log.Printf("[INFO] Repositories: %d selected on %d",
+		len(selected), len(repos))
+	return selected
+}
+
+func matchAny(patterns []*namePattern, repo *gh.Repository) bool {
+	for _, p := range patterns {
+		if p.Match(repo) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	"github.com/nlamirault/geronimo/config"
+	gh "github.com/nlamirault/geronimo/providers/github"
+)
+
+func newTestRepository(name string, stars int, language string) *gh.Repository {
+	return &gh.Repository{
+		Repository: github.Repository{
+			Name:            github.String(name),
+			FullName:        github.String("nlamirault/" + name),
+			StargazersCount: github.Int(stars),
+			Language:        github.String(language),
+			Fork:            github.Bool(false),
+			Private:         github.Bool(false),
+		},
+		Archived: github.Bool(false),
+		Topics:   []string{"monitoring"},
+	}
+}
+
+func TestRepositoryFilterPatterns(t *testing.T) {
+	filter, err := newRepositoryFilter(config.FilterConfig{
+		Include: []string{"nlamirault/*"},
+		Exclude: []string{"*-old", "/^test-[0-9]+$/"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]bool{
+		"geronimo":     true,
+		"geronimo-old": false,
+		"test-42":      false,
+		"test-foo":     true,
+	} {
+		if ok, _ := filter.Match(newTestRepository(name, 0, "Go")); ok != expected {
+			t.Fatalf("Invalid filter for %s: %t", name, ok)
+		}
+	}
+	repo := newTestRepository("geronimo", 0, "Go")
+	repo.FullName = github.String("portefaix/geronimo")
+	if ok, _ := filter.Match(repo); ok {
+		t.Fatalf("Repository not included: %s", *repo.FullName)
+	}
+}
+
+func TestRepositoryFilterAttributes(t *testing.T) {
+	filter, err := newRepositoryFilter(config.FilterConfig{
+		SkipForks:    true,
+		SkipArchived: true,
+		SkipPrivate:  true,
+		MinStars:     5,
+		Languages:    []string{"go"},
+		Topics:       []string{"Monitoring", "elasticsearch"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, reason := filter.Match(newTestRepository("geronimo", 10, "Go")); !ok {
+		t.Fatalf("Repository excluded: %s", reason)
+	}
+	fork := newTestRepository("geronimo", 10, "Go")
+	fork.Fork = github.Bool(true)
+	archived := newTestRepository("geronimo", 10, "Go")
+	archived.Archived = github.Bool(true)
+	private := newTestRepository("geronimo", 10, "Go")
+	private.Private = github.Bool(true)
+	untagged := newTestRepository("geronimo", 10, "Go")
+	untagged.Topics = nil
+	for _, repo := range []*gh.Repository{
+		fork, archived, private, untagged,
+		newTestRepository("geronimo", 2, "Go"),
+		newTestRepository("geronimo", 10, "Rust"),
+	} {
+		if ok, _ := filter.Match(repo); ok {
+			t.Fatalf("Repository not excluded: %#v", repo)
+		}
+	}
+}
+
+func TestRepositoryFilterInvalidPattern(t *testing.T) {
+	_, err := newRepositoryFilter(config.FilterConfig{
+		Exclude: []string{"/[a-/"},
+	})
+	if err == nil {
+		t.Fatalf("Invalid pattern accepted")
+	}
+}
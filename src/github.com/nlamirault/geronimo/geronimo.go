@@ -18,15 +18,40 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/nlamirault/geronimo/config"
 	"github.com/nlamirault/geronimo/logging"
 	"github.com/nlamirault/geronimo/version"
 )
 
+// configEnv is the environment variable naming the configuration file.
+const configEnv = "GERONIMO_CONFIG"
+
+// overrides are the configuration keys set on the command line, as
+// section.key=value.
+type overrides []string
+
+func (o *overrides) String() string {
+	return strings.Join(*o, ",")
+}
+
+func (o *overrides) Set(value string) error {
+	if !strings.Contains(value, "=") {
+		return fmt.Errorf("%s isn't a section.key=value pair", value)
+	}
+	*o = append(*o, value)
+	return nil
+}
+
 var (
-	vrsn  bool
-	debug bool
+	vrsn       bool
+	debug      bool
+	noCache    bool
+	configFile string
+	configSet  overrides
 )
 
 func init() {
@@ -34,37 +59,122 @@ func init() {
 	flag.BoolVar(&vrsn, "version", false, "print version and exit")
 	flag.BoolVar(&vrsn, "v", false, "print version and exit (shorthand)")
 	flag.BoolVar(&debug, "debug", false, "Enable debug mode")
+	flag.BoolVar(&noCache, "no-cache", false, "Bypass the GitHub responses cache")
+	flag.StringVar(&configFile, "config", "",
+		"Configuration file (default $"+configEnv+", then the XDG locations)")
+	flag.Var(&configSet, "set",
+		"Override a configuration key, as section.key=value (repeatable)")
 
 	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: geronimo [options] <command> [arguments]\n\n")
+		fmt.Fprintf(os.Stderr, "Commands:\n")
+		printCommands(os.Stderr, commands())
+		fmt.Fprintf(os.Stderr, "\nRun 'geronimo help <command>' for the help of a command.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
 		flag.PrintDefaults()
 	}
+}
+
+// configurationLocations returns the configuration files to look for: the
+// -config flag or the GERONIMO_CONFIG variable when set, otherwise the user
+// file of $XDG_CONFIG_HOME, then the system wide files.
+func configurationLocations() []string {
+	if configFile != "" {
+		return []string{configFile}
+	}
+	if path := os.Getenv(configEnv); path != "" {
+		return []string{path}
+	}
+	home := os.Getenv("XDG_CONFIG_HOME")
+	if home == "" {
+		home = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	locations := []string{filepath.Join(home, "geronimo", "geronimo.toml")}
+	dirs := os.Getenv("XDG_CONFIG_DIRS")
+	if dirs == "" {
+		dirs = "/etc/xdg"
+	}
+	for _, dir := range filepath.SplitList(dirs) {
+		if dir != "" {
+			locations = append(locations, filepath.Join(dir, "geronimo", "geronimo.toml"))
+		}
+	}
+	return append(locations, "/etc/geronimo/geronimo.toml")
+}
 
-	flag.Parse()
+// configurationNamed returns true if the configuration file is given by the
+// -config flag or the GERONIMO_CONFIG variable, so it must exist.
+func configurationNamed() bool {
+	return configFile != "" || os.Getenv(configEnv) != ""
 }
 
-func getConfigurationFile() string {
-	return "/home/nlamirault/.config/geronimo/geronimo.toml"
+// defaultConfigurationFile returns the configuration file written when none
+// exists yet.
+func defaultConfigurationFile() string {
+	return configurationLocations()[0]
 }
 
-func setup(filename string, debug bool) (*config.Configuration, error) {
+// getConfigurationFile returns the first existing configuration file.
+func getConfigurationFile() (string, error) {
+	locations := configurationLocations()
+	for _, path := range locations {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no configuration file found, tried: %s",
+		strings.Join(locations, ", "))
+}
+
+func setupLogging(debug bool) {
 	if debug {
 		logging.SetLogging("DEBUG")
 	} else {
 		logging.SetLogging("INFO")
 	}
-	return config.Load(filename)
+}
+
+// setup loads the configuration: the keys set on the command line override
+// the GERONIMO_ environment variables, which override the file. With
+// validate, an invalid configuration is an error.
+func setup(filename string, debug bool, validate bool) (*config.Configuration, error) {
+	setupLogging(debug)
+	conf, err := config.Load(filename)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range configSet {
+		kv := strings.SplitN(o, "=", 2)
+		if err := conf.Set(kv[0], kv[1]); err != nil {
+			return nil, err
+		}
+	}
+	if validate {
+		if errs := validateConfiguration(conf); len(errs) > 0 {
+			var messages []string
+			for _, err := range errs {
+				messages = append(messages, err.Error())
+			}
+			return nil, fmt.Errorf("invalid configuration: %s",
+				strings.Join(messages, ", "))
+		}
+	}
+	return conf, nil
+}
+
+func printVersion() {
+	fmt.Printf("Geronimo v%s\n", version.Version)
 }
 
 func main() {
+	flag.Parse()
 	if vrsn {
-		fmt.Printf("Geronimo v%s\n", version.Version)
+		printVersion()
 		return
 	}
-	conf, err := setup(getConfigurationFile(), debug)
-	if err != nil {
-		log.Printf("[ERROR] Can't setup : %s", err.Error())
-		return
+	err := runCommand(commands(), flag.Args())
+	if err != nil && err != flag.ErrHelp {
+		log.Printf("[ERROR] %s", err.Error())
+		os.Exit(1)
 	}
-	synchronize(conf)
-
 }
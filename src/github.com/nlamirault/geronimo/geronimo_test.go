@@ -13,3 +13,106 @@
 // limitations under the License.
 
 package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setTestEnv sets an environment variable and returns the function restoring
+// its previous value.
+func setTestEnv(key string, value string) func() {
+	old, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	return func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func TestConfigurationLocations(t *testing.T) {
+	defer setTestEnv(configEnv, "")()
+	defer setTestEnv("XDG_CONFIG_HOME", "/tmp/config")()
+	defer setTestEnv("XDG_CONFIG_DIRS", "/etc/xdg:/opt/etc")()
+	locations := configurationLocations()
+	expected := []string{
+		"/tmp/config/geronimo/geronimo.toml",
+		"/etc/xdg/geronimo/geronimo.toml",
+		"/opt/etc/geronimo/geronimo.toml",
+		"/etc/geronimo/geronimo.toml",
+	}
+	if strings.Join(locations, " ") != strings.Join(expected, " ") {
+		t.Fatalf("Invalid configuration locations: %v", locations)
+	}
+
+	defer setTestEnv(configEnv, "/tmp/env.toml")()
+	if locations := configurationLocations(); len(locations) != 1 || locations[0] != "/tmp/env.toml" {
+		t.Fatalf("Invalid environment configuration: %v", locations)
+	}
+	configFile = "/tmp/flag.toml"
+	defer func() { configFile = "" }()
+	if locations := configurationLocations(); len(locations) != 1 || locations[0] != "/tmp/flag.toml" {
+		t.Fatalf("Invalid flag configuration: %v", locations)
+	}
+}
+
+func TestGetConfigurationFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geronimo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer setTestEnv(configEnv, "")()
+	defer setTestEnv("XDG_CONFIG_HOME", filepath.Join(dir, "home"))()
+	defer setTestEnv("XDG_CONFIG_DIRS", filepath.Join(dir, "system"))()
+
+	_, err = getConfigurationFile()
+	if err == nil || !strings.Contains(err.Error(), filepath.Join(dir, "home", "geronimo", "geronimo.toml")) ||
+		!strings.Contains(err.Error(), "/etc/geronimo/geronimo.toml") {
+		t.Fatalf("Invalid missing configuration error: %v", err)
+	}
+
+	system := filepath.Join(dir, "system", "geronimo", "geronimo.toml")
+	os.MkdirAll(filepath.Dir(system), 0755)
+	if err := ioutil.WriteFile(system, []byte(""), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := getConfigurationFile(); err != nil || path != system {
+		t.Fatalf("Invalid configuration file: %s %v", path, err)
+	}
+}
+
+func TestNamedConfigurationMissing(t *testing.T) {
+	defer setTestEnv(configEnv, "")()
+	defer func() { configFile, configSet = "", nil }()
+	configFile = filepath.Join(os.TempDir(), "geronimo-missing.toml")
+	configSet = overrides{"github.api_token=secret"}
+	err := runCommand(commands(), []string{"config", "validate", "-offline"})
+	if err == nil || !strings.Contains(err.Error(), configFile) {
+		t.Fatalf("Invalid missing configuration: %v", err)
+	}
+}
+
+func TestInvalidConfiguration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geronimo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func() { configFile = "" }()
+	configFile = filepath.Join(dir, "geronimo.toml")
+	if err := ioutil.WriteFile(configFile, []byte("[github]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// Every command checks the configuration before running
+	err = runCommand(commands(), []string{"reindex"})
+	if err == nil || !strings.Contains(err.Error(), "api_token is required") {
+		t.Fatalf("Invalid configuration accepted: %v", err)
+	}
+}
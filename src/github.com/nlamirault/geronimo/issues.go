@@ -0,0 +1,102 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/go-github/github"
+
+	gh "github.com/nlamirault/geronimo/providers/github"
+	"github.com/nlamirault/geronimo/storage"
+)
+
+// retrieveRepositoryIssues retrieves the issues, open and closed, of a
+// repository updated since a date. Pull requests, which are also returned by
+// the GitHub issues API, are skipped.
+func retrieveRepositoryIssues(client *github.Client, repo *github.Repository, since time.Time) ([]gh.Issue, error) {
+	var issues []gh.Issue
+	for page := options.From/options.PerPage + 1; page != 0; {
+		i, resp, err := gh.ListIssuesByRepo(
+			client,
+			userLogin(repo.Owner),
+			*repo.Name,
+			&github.IssueListByRepoOptions{
+				ListOptions: github.ListOptions{
+					PerPage: options.PerPage,
+					Page:    page,
+				},
+				State: "all",
+				Since: since})
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range i {
+			if issue.PullRequestLinks != nil {
+				continue
+			}
+			issues = append(issues, issue)
+		}
+		page = nextPage(resp)
+	}
+	log.Printf("[DEBUG] Repository %s: %d issues", *repo.Name, len(issues))
+	return issues, nil
+}
+
+func saveIssues(backend storage.Backend, owner string, repo *github.Repository, issues []gh.Issue) error {
+	index := options.Layout.Index("issue", owner, *repo.Name)
+	for _, issue := range issues {
+		data := newIssue(repo, &issue)
+		data.Owner = owner
+		err := backend.Upsert(index, "issue",
+			options.Layout.ID(owner, *repo.Name, fmt.Sprintf("%d", data.Number)),
+			data)
+		if err != nil {
+			return fmt.Errorf("can't store issue %d: %s",
+				data.Number, err.Error())
+		}
+	}
+	log.Printf("[INFO] Indexed %d issues of %s to index %s",
+		len(issues), *repo.Name, index)
+	return nil
+}
+
+func newIssue(repo *github.Repository, issue *gh.Issue) storage.Issue {
+	labels := []string{}
+	for _, label := range issue.Labels {
+		labels = append(labels, stringValue(label.Name))
+	}
+	assignees := []string{}
+	for _, assignee := range issue.Assignees {
+		assignees = append(assignees, userLogin(assignee))
+	}
+	if len(assignees) == 0 && issue.Assignee != nil {
+		assignees = append(assignees, userLogin(issue.Assignee))
+	}
+	return storage.Issue{
+		Repository: *repo.Name,
+		Number:     intValue(issue.Number),
+		Title:      stringValue(issue.Title),
+		State:      stringValue(issue.State),
+		Labels:     labels,
+		Assignees:  assignees,
+		Author:     userLogin(issue.User),
+		Created:    issue.CreatedAt,
+		Closed:     issue.ClosedAt,
+		Comments:   intValue(issue.Comments),
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	gh "github.com/nlamirault/geronimo/providers/github"
+)
+
+func TestNewIssue(t *testing.T) {
+	repo := &github.Repository{Name: github.String("geronimo")}
+	issue := &gh.Issue{
+		Issue: github.Issue{
+			Number: github.Int(42),
+			Title:  github.String("Crash on sync"),
+			State:  github.String("closed"),
+			Labels: []github.Label{
+				github.Label{Name: github.String("bug")},
+			},
+			Assignee: &github.User{Login: github.String("nlamirault")},
+			User:     &github.User{Login: github.String("foo")},
+			Comments: github.Int(3),
+		},
+		Assignees: []*github.User{
+			&github.User{Login: github.String("nlamirault")},
+			&github.User{Login: github.String("bar")},
+		},
+	}
+	data := newIssue(repo, issue)
+	if data.Repository != "geronimo" || data.Number != 42 ||
+		data.State != "closed" || data.Author != "foo" ||
+		data.Comments != 3 {
+		t.Fatalf("Invalid issue: %#v", data)
+	}
+	if len(data.Labels) != 1 || data.Labels[0] != "bug" {
+		t.Fatalf("Invalid issue labels: %#v", data)
+	}
+	if len(data.Assignees) != 2 || data.Assignees[0] != "nlamirault" ||
+		data.Assignees[1] != "bar" {
+		t.Fatalf("Invalid issue assignees: %#v", data)
+	}
+}
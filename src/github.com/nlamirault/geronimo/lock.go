@@ -0,0 +1,72 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nlamirault/geronimo/config"
+)
+
+// errLocked is returned when another synchronization is running.
+var errLocked = errors.New("another synchronization is running")
+
+// syncLock prevents concurrent synchronizations, from the daemon or from the
+// command line. With flock(2), the lock is released by the system if the
+// process dies; elsewhere, the lock file is created exclusively and a stale
+// one, left by a dead process, is replaced.
+type syncLock struct {
+	file *os.File
+}
+
+// lockFile returns the path of the lock file.
+func lockFile(conf *config.Configuration) string {
+	if conf.Daemon.LockFile != "" {
+		return conf.Daemon.LockFile
+	}
+	return filepath.Join(os.Getenv("HOME"), ".cache", "geronimo", "geronimo.lock")
+}
+
+// acquireLock takes the lock, or returns errLocked if it is already taken.
+func acquireLock(path string) (*syncLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("can't create lock directory: %s", err.Error())
+	}
+	file, err := openLock(path)
+	if err == errLocked {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't lock %s: %s", path, err.Error())
+	}
+	lock := &syncLock{file: file}
+	if err := file.Truncate(0); err != nil {
+		lock.Release()
+		return nil, fmt.Errorf("can't write lock file: %s", err.Error())
+	}
+	if _, err := fmt.Fprintf(file, "%d\n", os.Getpid()); err != nil {
+		lock.Release()
+		return nil, fmt.Errorf("can't write lock file: %s", err.Error())
+	}
+	return lock, nil
+}
+
+// Release releases the lock.
+func (l *syncLock) Release() error {
+	return unlock(l.file)
+}
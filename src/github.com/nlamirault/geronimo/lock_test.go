@@ -0,0 +1,80 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSyncLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geronimo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "geronimo.lock")
+	lock, err := acquireLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := acquireLock(path); err != errLocked {
+		t.Fatalf("Invalid concurrent lock: %v", err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+	lock, err = acquireLock(path)
+	if err != nil {
+		t.Fatalf("Invalid lock after release: %v", err)
+	}
+	lock.Release()
+}
+
+func TestExclusiveLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geronimo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "geronimo.lock")
+	file, err := openExclusiveLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintf(file, "%d\n", os.Getpid())
+	if _, err := openExclusiveLock(path); err != errLocked {
+		t.Fatalf("Invalid concurrent lock: %v", err)
+	}
+	if err := removeExclusiveLock(file); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Invalid released lock: %v", err)
+	}
+
+	// A lock file of a dead process is stale
+	if err := ioutil.WriteFile(path, []byte("999999999\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err = openExclusiveLock(path)
+	if err != nil {
+		t.Fatalf("Invalid stale lock: %v", err)
+	}
+	removeExclusiveLock(file)
+}
@@ -0,0 +1,150 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/github"
+
+	gh "github.com/nlamirault/geronimo/providers/github"
+	"github.com/nlamirault/geronimo/storage"
+)
+
+// synchronizeOrganization synchronizes an organization and its repositories.
+func synchronizeOrganization(ghClient *github.Client, backend storage.Backend, login string) error {
+	org, _, err := ghClient.Organizations.Get(login)
+	if err != nil {
+		return err
+	}
+	log.Printf("[DEBUG] Github organization: %s", org)
+	repos, err := retrieveOrganizationRepositories(ghClient, org)
+	if err != nil {
+		return err
+	}
+	members, err := retrieveOrganizationMembers(ghClient, org)
+	if err != nil {
+		return err
+	}
+	teams, err := retrieveOrganizationTeams(ghClient, org)
+	if err != nil {
+		return err
+	}
+	if err := indexingOrganization(backend, org, members, teams); err != nil {
+		return err
+	}
+	reconciled, err := reconcileRepositories(ghClient, backend, *org.Login, repos)
+	if err != nil {
+		return err
+	}
+	reconciled.Log()
+	return execute(*org.Login, filterRepositories(repos), ghClient, backend)
+}
+
+// retrieveOrganizationRepositories retrieves all the repositories of an
+// organization, including the private ones visible with the token.
+func retrieveOrganizationRepositories(client *github.Client, org *github.Organization) ([]gh.Repository, error) {
+	var repos []gh.Repository
+	for page := options.From/options.PerPage + 1; page != 0; {
+		r, resp, err := gh.ListRepositoriesByOrg(
+			client,
+			*org.Login,
+			&github.RepositoryListByOrgOptions{
+				ListOptions: github.ListOptions{
+					PerPage: options.PerPage,
+					Page:    page,
+				},
+				Type: "all"})
+		if err != nil {
+			return nil, fmt.Errorf("can't retrieve repositories: %s", err.Error())
+		}
+		repos = append(repos, r...)
+		page = nextPage(resp)
+	}
+	return repos, nil
+}
+
+func retrieveOrganizationMembers(client *github.Client, org *github.Organization) ([]github.User, error) {
+	var members []github.User
+	for page := options.From/options.PerPage + 1; page != 0; {
+		m, resp, err := client.Organizations.ListMembers(
+			*org.Login,
+			&github.ListMembersOptions{
+				ListOptions: github.ListOptions{
+					PerPage: options.PerPage,
+					Page:    page,
+				}})
+		if err != nil {
+			return nil, fmt.Errorf("can't retrieve members: %s", err.Error())
+		}
+		members = append(members, m...)
+		page = nextPage(resp)
+	}
+	return members, nil
+}
+
+func retrieveOrganizationTeams(client *github.Client, org *github.Organization) ([]github.Team, error) {
+	var teams []github.Team
+	for page := options.From/options.PerPage + 1; page != 0; {
+		t, resp, err := client.Organizations.ListTeams(
+			*org.Login,
+			&github.ListOptions{
+				PerPage: options.PerPage,
+				Page:    page,
+			})
+		if err != nil {
+			if resp != nil && resp.StatusCode == http.StatusForbidden {
+				// Teams are only visible to the members
+				log.Printf("[WARN] Teams of %s are not visible", *org.Login)
+				return nil, nil
+			}
+			return nil, fmt.Errorf("can't retrieve teams: %s", err.Error())
+		}
+		teams = append(teams, t...)
+		page = nextPage(resp)
+	}
+	return teams, nil
+}
+
+func indexingOrganization(backend storage.Backend, org *github.Organization, members []github.User, teams []github.Team) error {
+	owner := strings.ToLower(*org.Login)
+	index := options.Layout.Index("organization", owner, "")
+	if err := backend.CreateNamespace(index); err != nil {
+		return fmt.Errorf("can't create index: %s", err.Error())
+	}
+	data := newOrganization(org, members, teams)
+	return backend.Upsert(index, "organization",
+		options.Layout.ID(owner, "", fmt.Sprintf("%d", *org.ID)), data)
+}
+
+func newOrganization(org *github.Organization, members []github.User, teams []github.Team) storage.Organization {
+	data := storage.Organization{
+		Login:       *org.Login,
+		Name:        stringValue(org.Name),
+		Company:     stringValue(org.Company),
+		Email:       stringValue(org.Email),
+		Location:    stringValue(org.Location),
+		PublicRepos: intValue(org.PublicRepos),
+		Members:     len(members),
+		Teams:       []string{},
+	}
+	for _, team := range teams {
+		data.Teams = append(data.Teams, stringValue(team.Name))
+	}
+	return data
+}
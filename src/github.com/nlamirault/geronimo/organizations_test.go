@@ -0,0 +1,44 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestNewOrganization(t *testing.T) {
+	org := &github.Organization{
+		Login:       github.String("docker"),
+		Name:        github.String("Docker"),
+		PublicRepos: github.Int(120),
+	}
+	members := []github.User{
+		github.User{Login: github.String("foo")},
+		github.User{Login: github.String("bar")},
+	}
+	teams := []github.Team{
+		github.Team{Name: github.String("maintainers")},
+	}
+	data := newOrganization(org, members, teams)
+	if data.Login != "docker" || data.Name != "Docker" ||
+		data.PublicRepos != 120 || data.Members != 2 {
+		t.Fatalf("Invalid organization: %#v", data)
+	}
+	if len(data.Teams) != 1 || data.Teams[0] != "maintainers" {
+		t.Fatalf("Invalid organization teams: %#v", data)
+	}
+}
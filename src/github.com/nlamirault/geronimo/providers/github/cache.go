@@ -0,0 +1,257 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCacheSize is the default maximum size in bytes of the cache.
+	DefaultCacheSize = 100 * 1024 * 1024
+
+	headerETag            = "ETag"
+	headerLastModified    = "Last-Modified"
+	headerIfNoneMatch     = "If-None-Match"
+	headerIfModifiedSince = "If-Modified-Since"
+
+	// cacheTempPrefix is the prefix of the files being written
+	cacheTempPrefix = ".tmp-"
+)
+
+// Cache is a http.RoundTripper which stores the GitHub responses on disk,
+// and sends conditional requests using their ETag and Last-Modified headers.
+// GitHub answers with a 304 Not Modified, which doesn't count against the
+// quota, if the resource has not changed; the stored response is then
+// returned.
+type Cache struct {
+	// Dir is the directory of the cached responses.
+	Dir string
+
+	// MaxSize is the maximum size in bytes of the cached responses. The
+	// least recently used responses are removed when it is exceeded, until
+	// the cache is 10% under it, so the directory isn't read on every store.
+	MaxSize int64
+
+	transport http.RoundTripper
+
+	mu     sync.Mutex
+	hits   int
+	misses int
+	// size counts the bytes of the cached responses once sized is set by
+	// the first eviction
+	size  int64
+	sized bool
+}
+
+// NewCache creates a new Cache into a directory.
+func NewCache(dir string, maxSize int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultCacheSize
+	}
+	return &Cache{
+		Dir:     dir,
+		MaxSize: maxSize,
+	}, nil
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (c *Cache) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" {
+		return c.transport.RoundTrip(req)
+	}
+	filename := c.filename(req)
+	cached := c.load(filename, req)
+	if cached != nil {
+		conditional := new(http.Request)
+		*conditional = *req
+		conditional.Header = http.Header{}
+		for k, v := range req.Header {
+			conditional.Header[k] = v
+		}
+		if etag := cached.Header.Get(headerETag); etag != "" {
+			conditional.Header.Set(headerIfNoneMatch, etag)
+		}
+		if modified := cached.Header.Get(headerLastModified); modified != "" {
+			conditional.Header.Set(headerIfModifiedSince, modified)
+		}
+		req = conditional
+	}
+
+	resp, err := c.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		resp.Body.Close()
+		for _, header := range []string{headerRateLimit, headerRateRemaining, headerRateReset} {
+			if v := resp.Header.Get(header); v != "" {
+				cached.Header.Set(header, v)
+			}
+		}
+		now := time.Now()
+		os.Chtimes(filename, now, now)
+		c.count(true)
+		return cached, nil
+	}
+	if cached != nil {
+		cached.Body.Close()
+	}
+	c.count(false)
+	if resp.StatusCode == http.StatusOK &&
+		(resp.Header.Get(headerETag) != "" || resp.Header.Get(headerLastModified) != "") {
+		if err := c.store(filename, resp); err != nil {
+			log.Printf("[WARN] Can't cache %s: %s", req.URL, err.Error())
+		}
+	}
+	return resp, nil
+}
+
+// filename returns the file of the cached response of a request. The
+// credentials and the media type are part of the key, as they change the
+// response.
+func (c *Cache) filename(req *http.Request) string {
+	key := fmt.Sprintf("%s\n%s\n%s", req.URL.String(),
+		req.Header.Get("Authorization"), req.Header.Get("Accept"))
+	return filepath.Join(c.Dir, fmt.Sprintf("%x", sha256.Sum256([]byte(key))))
+}
+
+func (c *Cache) load(filename string, req *http.Request) *http.Response {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+	if err != nil {
+		log.Printf("[WARN] Invalid cached response %s: %s", filename, err.Error())
+		os.Remove(filename)
+		return nil
+	}
+	return resp
+}
+
+// store writes a response into a temporary file, renamed once complete, so
+// a response is never read partially written. The size of the cache is
+// counted on each store; the directory is only read to evict responses.
+func (c *Cache) store(filename string, resp *http.Response) error {
+	data, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > c.MaxSize {
+		return nil
+	}
+	tmp, err := ioutil.TempFile(c.Dir, cacheTempPrefix)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	var replaced int64
+	if info, err := os.Stat(filename); err == nil {
+		replaced = info.Size()
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	c.mu.Lock()
+	c.size += int64(len(data)) - replaced
+	full := !c.sized || c.size > c.MaxSize
+	c.mu.Unlock()
+	if full {
+		return c.evict()
+	}
+	return nil
+}
+
+// evict removes the least recently used responses until the size of the
+// cache is 10% under its maximum size, if it is exceeded, and counts the
+// size of the remaining responses.
+func (c *Cache) evict() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	files, err := ioutil.ReadDir(c.Dir)
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, file := range files {
+		size += file.Size()
+	}
+	c.size, c.sized = size, true
+	if size <= c.MaxSize {
+		return nil
+	}
+	target := c.MaxSize - c.MaxSize/10
+	sort.Sort(byModTime(files))
+	for _, file := range files {
+		if size <= target {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.Dir, file.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		size -= file.Size()
+	}
+	c.size = size
+	return nil
+}
+
+func (c *Cache) count(hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if hit {
+		c.hits++
+	} else {
+		c.misses++
+	}
+}
+
+// LogStats writes the cache usage.
+func (c *Cache) LogStats() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	log.Printf("[INFO] GitHub cache: %d not modified, %d downloaded",
+		c.hits, c.misses)
+}
+
+type byModTime []os.FileInfo
+
+func (f byModTime) Len() int           { return len(f) }
+func (f byModTime) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+func (f byModTime) Less(i, j int) bool { return f[i].ModTime().Before(f[j].ModTime()) }
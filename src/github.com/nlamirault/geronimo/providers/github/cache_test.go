@@ -0,0 +1,142 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func newTestCache(t *testing.T, maxSize int64) *Cache {
+	dir, err := ioutil.TempDir("", "geronimo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := NewCache(dir, maxSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.transport = http.DefaultTransport
+	return cache
+}
+
+func getBody(t *testing.T, client *http.Client, url string) string {
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Invalid status code: %d", resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestCacheConditionalRequests(t *testing.T) {
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(headerIfNoneMatch) == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set(headerETag, `"v1"`)
+		fmt.Fprint(w, `{"name":"geronimo"}`)
+	}))
+	defer server.Close()
+
+	cache := newTestCache(t, 0)
+	defer os.RemoveAll(cache.Dir)
+	client := &http.Client{Transport: cache}
+	for i := 0; i < 3; i++ {
+		if body := getBody(t, client, server.URL); body != `{"name":"geronimo"}` {
+			t.Fatalf("Invalid body: %s", body)
+		}
+	}
+	if downloads != 1 || cache.hits != 2 || cache.misses != 1 {
+		t.Fatalf("Invalid cache usage: %d downloads, %d hits, %d misses",
+			downloads, cache.hits, cache.misses)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerETag, fmt.Sprintf(`"%s"`, r.URL.Path))
+		fmt.Fprint(w, `{"name":"geronimo"}`)
+	}))
+	defer server.Close()
+
+	cache := newTestCache(t, 300)
+	defer os.RemoveAll(cache.Dir)
+	client := &http.Client{Transport: cache}
+	for i := 0; i < 5; i++ {
+		getBody(t, client, fmt.Sprintf("%s/repos/%d", server.URL, i))
+	}
+	files, err := ioutil.ReadDir(cache.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var size int64
+	for _, file := range files {
+		size += file.Size()
+	}
+	if len(files) == 0 || size > cache.MaxSize-cache.MaxSize/10 {
+		t.Fatalf("Invalid cache size: %d files, %d bytes", len(files), size)
+	}
+	if cache.size != size {
+		t.Fatalf("Invalid counted size: %d, expected %d", cache.size, size)
+	}
+}
+
+func TestCacheSizeCounter(t *testing.T) {
+	version := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerETag, fmt.Sprintf(`"%d"`, version))
+		fmt.Fprintf(w, `{"name":"geronimo","version":%d}`, version)
+	}))
+	defer server.Close()
+
+	cache := newTestCache(t, 0)
+	defer os.RemoveAll(cache.Dir)
+	client := &http.Client{Transport: cache}
+	for version = 0; version < 12; version++ {
+		getBody(t, client, fmt.Sprintf("%s/repos/%d", server.URL, version%3))
+	}
+	files, err := ioutil.ReadDir(cache.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var size int64
+	for _, file := range files {
+		if strings.HasPrefix(file.Name(), cacheTempPrefix) {
+			t.Fatalf("Invalid temporary file: %s", file.Name())
+		}
+		size += file.Size()
+	}
+	// The replaced responses are not counted twice
+	if len(files) != 3 || cache.size != size {
+		t.Fatalf("Invalid counted size: %d files, %d bytes, expected %d",
+			len(files), cache.size, size)
+	}
+}
@@ -15,22 +15,50 @@
 package github
 
 import (
-	"log"
 	"net/http"
+	"net/url"
+	"reflect"
 
 	gh "github.com/google/go-github/github"
+	"github.com/google/go-querystring/query"
 	"golang.org/x/oauth2"
 )
 
-// NewClient creates a new Github client.
-func NewClient(token string) *gh.Client {
-	var tc *http.Client
+// NewClient creates a new Github client. All the requests are sent through
+// the rate limiter, then through the cache if it is not nil.
+func NewClient(token string, limiter *RateLimiter, cache *Cache) *gh.Client {
+	var transport http.RoundTripper = http.DefaultTransport
+	if cache != nil {
+		cache.transport = transport
+		transport = cache
+	}
 	if token != "" {
-		log.Printf("[DEBUG] Github token: %s", token)
 		ts := oauth2.StaticTokenSource(&oauth2.Token{
 			AccessToken: token,
 		})
-		tc = oauth2.NewClient(oauth2.NoContext, ts)
+		transport = &oauth2.Transport{
+			Source: ts,
+			Base:   transport,
+		}
+	}
+	limiter.transport = transport
+	return gh.NewClient(&http.Client{Transport: limiter})
+}
+
+// addOptions adds the parameters in opt as URL query parameters to s.
+func addOptions(s string, opt interface{}) (string, error) {
+	v := reflect.ValueOf(opt)
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return s, nil
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return s, err
+	}
+	qs, err := query.Values(opt)
+	if err != nil {
+		return s, err
 	}
-	return gh.NewClient(tc)
+	u.RawQuery = qs.Encode()
+	return u.String(), nil
 }
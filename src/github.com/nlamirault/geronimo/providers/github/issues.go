@@ -0,0 +1,47 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"fmt"
+
+	gh "github.com/google/go-github/github"
+)
+
+// Issue is a GitHub issue with the fields which are not yet available in
+// go-github.
+type Issue struct {
+	gh.Issue
+	Assignees []*gh.User `json:"assignees,omitempty"`
+}
+
+// ListIssuesByRepo lists the issues of a repository.
+func ListIssuesByRepo(client *gh.Client, owner string, repo string, opt *gh.IssueListByRepoOptions) ([]Issue, *gh.Response, error) {
+	u := fmt.Sprintf("repos/%s/%s/issues", owner, repo)
+	u, err := addOptions(u, opt)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	issues := new([]Issue)
+	resp, err := client.Do(req, issues)
+	if err != nil {
+		return nil, resp, err
+	}
+	return *issues, resp, nil
+}
@@ -0,0 +1,73 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"fmt"
+	"time"
+
+	gh "github.com/google/go-github/github"
+)
+
+// PullRequest is a GitHub pull request with the fields which are not yet
+// available in go-github.
+type PullRequest struct {
+	gh.PullRequest
+	Draft          *bool `json:"draft,omitempty"`
+	ReviewComments *int  `json:"review_comments,omitempty"`
+}
+
+// PullRequestReview is a review submitted on a pull request.
+type PullRequestReview struct {
+	ID          *int       `json:"id,omitempty"`
+	User        *gh.User   `json:"user,omitempty"`
+	State       *string    `json:"state,omitempty"`
+	SubmittedAt *time.Time `json:"submitted_at,omitempty"`
+}
+
+// GetPullRequest retrieves a single pull request. Unlike the list of pull
+// requests, it contains the additions, deletions, changed files and merger.
+func GetPullRequest(client *gh.Client, owner string, repo string, number int) (*PullRequest, *gh.Response, error) {
+	u := fmt.Sprintf("repos/%v/%v/pulls/%d", owner, repo, number)
+	req, err := client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	pull := new(PullRequest)
+	resp, err := client.Do(req, pull)
+	if err != nil {
+		return nil, resp, err
+	}
+	return pull, resp, nil
+}
+
+// ListPullRequestReviews lists the reviews of a pull request.
+func ListPullRequestReviews(client *gh.Client, owner string, repo string, number int, opt *gh.ListOptions) ([]PullRequestReview, *gh.Response, error) {
+	u := fmt.Sprintf("repos/%v/%v/pulls/%d/reviews", owner, repo, number)
+	u, err := addOptions(u, opt)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	reviews := new([]PullRequestReview)
+	resp, err := client.Do(req, reviews)
+	if err != nil {
+		return nil, resp, err
+	}
+	return *reviews, resp, nil
+}
@@ -0,0 +1,181 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	headerRateLimit     = "X-RateLimit-Limit"
+	headerRateRemaining = "X-RateLimit-Remaining"
+	headerRateReset     = "X-RateLimit-Reset"
+	headerRetryAfter    = "Retry-After"
+
+	// DefaultMaxRetries is the default number of retries of a request
+	// rejected by a rate limit.
+	DefaultMaxRetries = 5
+
+	// DefaultBackoff is the default delay before retrying a request rejected
+	// by a secondary rate limit without Retry-After header. It is doubled on
+	// each retry.
+	DefaultBackoff = time.Minute
+)
+
+// RateLimiter is a http.RoundTripper which tracks the GitHub API quota.
+// It pauses the requests until the reset time when the quota is exhausted,
+// and retries the requests rejected by the secondary (abuse) rate limits.
+// A RateLimiter is safe for concurrent use, so it is shared by all the
+// requests of a synchronization.
+type RateLimiter struct {
+	// MaxRetries is the number of retries of a rejected request.
+	MaxRetries int
+
+	// Backoff is the initial delay between retries of a request rejected by
+	// a secondary rate limit.
+	Backoff time.Duration
+
+	transport http.RoundTripper
+	sleep     func(time.Duration)
+
+	mu        sync.Mutex
+	limit     int
+	remaining int
+	reset     time.Time
+	requests  int
+	retries   int
+}
+
+// NewRateLimiter creates a new RateLimiter.
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		MaxRetries: DefaultMaxRetries,
+		Backoff:    DefaultBackoff,
+		remaining:  -1,
+		sleep:      time.Sleep,
+	}
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (l *RateLimiter) RoundTrip(req *http.Request) (*http.Response, error) {
+	backoff := l.Backoff
+	for retry := 0; ; retry++ {
+		l.waitForReset()
+		resp, err := l.transport.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		l.update(resp)
+		wait, limited := l.retryDelay(resp, backoff)
+		if !limited || retry >= l.MaxRetries || req.Body != nil {
+			return resp, nil
+		}
+		resp.Body.Close()
+		l.mu.Lock()
+		l.retries++
+		l.mu.Unlock()
+		log.Printf("[WARN] GitHub rate limit exceeded, retry %s in %s",
+			req.URL.Path, wait)
+		l.sleep(wait)
+		backoff *= 2
+	}
+}
+
+// waitForReset pauses until the reset time if the quota is exhausted.
+func (l *RateLimiter) waitForReset() {
+	l.mu.Lock()
+	remaining, reset := l.remaining, l.reset
+	l.mu.Unlock()
+	if remaining != 0 {
+		return
+	}
+	if wait := reset.Sub(time.Now()); wait > 0 {
+		log.Printf("[WARN] GitHub quota exhausted, wait until %s", reset)
+		l.sleep(wait)
+	}
+}
+
+// update records the quota sent by GitHub.
+func (l *RateLimiter) update(resp *http.Response) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.requests++
+	if limit := resp.Header.Get(headerRateLimit); limit != "" {
+		l.limit, _ = strconv.Atoi(limit)
+	}
+	if remaining := resp.Header.Get(headerRateRemaining); remaining != "" {
+		l.remaining, _ = strconv.Atoi(remaining)
+	}
+	if reset := resp.Header.Get(headerRateReset); reset != "" {
+		if v, _ := strconv.ParseInt(reset, 10, 64); v != 0 {
+			l.reset = time.Unix(v, 0)
+		}
+	}
+}
+
+// retryDelay checks if a response has been rejected by a rate limit, and
+// returns the delay before retrying the request.
+func (l *RateLimiter) retryDelay(resp *http.Response, backoff time.Duration) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden &&
+		resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if retryAfter := resp.Header.Get(headerRetryAfter); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	l.mu.Lock()
+	remaining, reset := l.remaining, l.reset
+	l.mu.Unlock()
+	if remaining == 0 {
+		// waitForReset pauses until the reset of the quota
+		return 0, !reset.IsZero()
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return 0, false
+	}
+	message := strings.ToLower(string(body))
+	if strings.Contains(message, "abuse") ||
+		strings.Contains(message, "secondary rate limit") {
+		return backoff, true
+	}
+	return 0, false
+}
+
+// Stats returns the number of requests and retries performed, and the
+// last known quota.
+func (l *RateLimiter) Stats() (requests int, retries int, remaining int, limit int, reset time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.requests, l.retries, l.remaining, l.limit, l.reset
+}
+
+// LogStats writes the quota usage.
+func (l *RateLimiter) LogStats() {
+	requests, retries, remaining, limit, reset := l.Stats()
+	log.Printf("[INFO] GitHub API: %d requests, %d retries, quota %d/%d until %s",
+		requests, retries, remaining, limit, reset)
+}
@@ -0,0 +1,112 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestRateLimiter() (*RateLimiter, *[]time.Duration) {
+	var sleeps []time.Duration
+	limiter := NewRateLimiter()
+	limiter.transport = http.DefaultTransport
+	limiter.sleep = func(d time.Duration) {
+		sleeps = append(sleeps, d)
+	}
+	return limiter, &sleeps
+}
+
+func TestRateLimiterTracksQuota(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Unix()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerRateLimit, "5000")
+		w.Header().Set(headerRateRemaining, "4999")
+		w.Header().Set(headerRateReset, fmt.Sprintf("%d", reset))
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	limiter, sleeps := newTestRateLimiter()
+	client := &http.Client{Transport: limiter}
+	if _, err := client.Get(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	requests, retries, remaining, limit, resetAt := limiter.Stats()
+	if requests != 1 || retries != 0 || remaining != 4999 || limit != 5000 ||
+		resetAt.Unix() != reset {
+		t.Fatalf("Invalid quota: %d %d %d %d %s",
+			requests, retries, remaining, limit, resetAt)
+	}
+	if len(*sleeps) != 0 {
+		t.Fatalf("Invalid pauses: %v", *sleeps)
+	}
+}
+
+func TestRateLimiterRetriesSecondaryLimit(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch calls {
+		case 1:
+			w.Header().Set(headerRetryAfter, "30")
+			w.WriteHeader(http.StatusForbidden)
+		case 2:
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message":"You have exceeded a secondary rate limit"}`)
+		default:
+			fmt.Fprint(w, `{}`)
+		}
+	}))
+	defer server.Close()
+
+	limiter, sleeps := newTestRateLimiter()
+	limiter.Backoff = time.Second
+	client := &http.Client{Transport: limiter}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || calls != 3 {
+		t.Fatalf("Invalid response: %d after %d calls", resp.StatusCode, calls)
+	}
+	if len(*sleeps) != 2 || (*sleeps)[0] != 30*time.Second ||
+		(*sleeps)[1] != 2*time.Second {
+		t.Fatalf("Invalid pauses: %v", *sleeps)
+	}
+}
+
+func TestRateLimiterDoesNotRetryForbidden(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"message":"Must have admin rights to Repository."}`)
+	}))
+	defer server.Close()
+
+	limiter, _ := newTestRateLimiter()
+	client := &http.Client{Transport: limiter}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusForbidden || calls != 1 {
+		t.Fatalf("Invalid response: %d after %d calls", resp.StatusCode, calls)
+	}
+}
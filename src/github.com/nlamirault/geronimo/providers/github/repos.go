@@ -0,0 +1,63 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"fmt"
+
+	gh "github.com/google/go-github/github"
+)
+
+const (
+	// mediaTypeTopicsPreview is the media type to retrieve the topics of
+	// the repositories
+	mediaTypeTopicsPreview = "application/vnd.github.mercy-preview+json"
+)
+
+// Repository is a GitHub repository with the fields which are not yet
+// available in go-github.
+type Repository struct {
+	gh.Repository
+	Archived *bool    `json:"archived,omitempty"`
+	Topics   []string `json:"topics,omitempty"`
+}
+
+// ListRepositories lists the repositories of a user.
+func ListRepositories(client *gh.Client, user string, opt *gh.RepositoryListOptions) ([]Repository, *gh.Response, error) {
+	return listRepositories(client, fmt.Sprintf("users/%v/repos", user), opt)
+}
+
+// ListRepositoriesByOrg lists the repositories of an organization.
+func ListRepositoriesByOrg(client *gh.Client, org string, opt *gh.RepositoryListByOrgOptions) ([]Repository, *gh.Response, error) {
+	return listRepositories(client, fmt.Sprintf("orgs/%v/repos", org), opt)
+}
+
+func listRepositories(client *gh.Client, u string, opt interface{}) ([]Repository, *gh.Response, error) {
+	u, err := addOptions(u, opt)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", mediaTypeTopicsPreview)
+	repos := new([]Repository)
+	resp, err := client.Do(req, repos)
+	if err != nil {
+		return nil, resp, err
+	}
+	return *repos, resp, nil
+}
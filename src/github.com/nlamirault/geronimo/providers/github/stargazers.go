@@ -0,0 +1,54 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"fmt"
+
+	gh "github.com/google/go-github/github"
+)
+
+const (
+	// mediaTypeStarringPreview is the media type to retrieve the date of
+	// the stars
+	mediaTypeStarringPreview = "application/vnd.github.v3.star+json"
+)
+
+// Stargazer is a user who starred a repository.
+type Stargazer struct {
+	StarredAt *gh.Timestamp `json:"starred_at,omitempty"`
+	User      *gh.User      `json:"user,omitempty"`
+}
+
+// ListStargazers lists the users who starred a repository with the date of
+// their star, from the oldest to the newest.
+func ListStargazers(client *gh.Client, owner string, repo string, opt *gh.ListOptions) ([]Stargazer, *gh.Response, error) {
+	u := fmt.Sprintf("repos/%s/%s/stargazers", owner, repo)
+	u, err := addOptions(u, opt)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", mediaTypeStarringPreview)
+	stargazers := new([]Stargazer)
+	resp, err := client.Do(req, stargazers)
+	if err != nil {
+		return nil, resp, err
+	}
+	return *stargazers, resp, nil
+}
@@ -0,0 +1,171 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/go-github/github"
+
+	gh "github.com/nlamirault/geronimo/providers/github"
+	"github.com/nlamirault/geronimo/storage"
+)
+
+// pullRequestData is a pull request with its reviews.
+type pullRequestData struct {
+	PullRequest *gh.PullRequest
+	Reviews     []gh.PullRequestReview
+}
+
+// retrieveRepositoryPullRequests retrieves the pull requests of a repository
+// updated since a date, with their details and reviews.
+func retrieveRepositoryPullRequests(client *github.Client, repo *github.Repository, since time.Time) ([]pullRequestData, error) {
+	var pulls []github.PullRequest
+	owner := userLogin(repo.Owner)
+	// The pull requests are listed from the last updated one, so stop at
+	// the first one not updated since the date.
+	for page := options.From/options.PerPage + 1; page != 0; {
+		p, resp, err := client.PullRequests.List(
+			owner,
+			*repo.Name,
+			&github.PullRequestListOptions{
+				ListOptions: github.ListOptions{
+					PerPage: options.PerPage,
+					Page:    page,
+				},
+				State:     "all",
+				Sort:      "updated",
+				Direction: "desc"})
+		if err != nil {
+			return nil, err
+		}
+		page = nextPage(resp)
+		for _, pull := range p {
+			if pull.UpdatedAt != nil && !pull.UpdatedAt.After(since) {
+				page = 0
+				break
+			}
+			pulls = append(pulls, pull)
+		}
+	}
+
+	var data []pullRequestData
+	for _, pull := range pulls {
+		number := intValue(pull.Number)
+		pr, _, err := gh.GetPullRequest(client, owner, *repo.Name, number)
+		if err != nil {
+			return nil, err
+		}
+		reviews, err := retrievePullRequestReviews(client, owner, *repo.Name, number)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, pullRequestData{
+			PullRequest: pr,
+			Reviews:     reviews,
+		})
+	}
+	log.Printf("[DEBUG] Repository %s: %d pull requests", *repo.Name, len(data))
+	return data, nil
+}
+
+func retrievePullRequestReviews(client *github.Client, owner string, name string, number int) ([]gh.PullRequestReview, error) {
+	var reviews []gh.PullRequestReview
+	for page := options.From/options.PerPage + 1; page != 0; {
+		r, resp, err := gh.ListPullRequestReviews(
+			client, owner, name, number,
+			&github.ListOptions{
+				PerPage: options.PerPage,
+				Page:    page,
+			})
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, r...)
+		page = nextPage(resp)
+	}
+	return reviews, nil
+}
+
+func savePullRequests(backend storage.Backend, owner string, repo *github.Repository, pulls []pullRequestData) error {
+	index := options.Layout.Index("pull_request", owner, *repo.Name)
+	for _, pull := range pulls {
+		data := newPullRequest(repo, pull)
+		data.Owner = owner
+		err := backend.Upsert(index, "pull_request",
+			options.Layout.ID(owner, *repo.Name, fmt.Sprintf("%d", data.Number)),
+			data)
+		if err != nil {
+			return fmt.Errorf("can't store pull request %d: %s",
+				data.Number, err.Error())
+		}
+	}
+	log.Printf("[INFO] Indexed %d pull requests of %s to index %s",
+		len(pulls), *repo.Name, index)
+	return nil
+}
+
+func newPullRequest(repo *github.Repository, data pullRequestData) storage.PullRequest {
+	pull := data.PullRequest
+	pr := storage.PullRequest{
+		Repository:     *repo.Name,
+		Number:         intValue(pull.Number),
+		Title:          stringValue(pull.Title),
+		State:          stringValue(pull.State),
+		Author:         userLogin(pull.User),
+		Additions:      intValue(pull.Additions),
+		Deletions:      intValue(pull.Deletions),
+		ChangedFiles:   intValue(pull.ChangedFiles),
+		Commits:        intValue(pull.Commits),
+		Comments:       intValue(pull.Comments),
+		ReviewComments: intValue(pull.ReviewComments),
+		Reviews:        len(data.Reviews),
+		MergedBy:       userLogin(pull.MergedBy),
+		Created:        pull.CreatedAt,
+		MergedAt:       pull.MergedAt,
+		Closed:         pull.ClosedAt,
+	}
+	if pull.Base != nil {
+		pr.BaseBranch = stringValue(pull.Base.Ref)
+	}
+	if pull.Head != nil {
+		pr.HeadBranch = stringValue(pull.Head.Ref)
+	}
+	if pull.Draft != nil {
+		pr.Draft = *pull.Draft
+	}
+	if pull.Merged != nil {
+		pr.Merged = *pull.Merged
+	}
+	for _, review := range data.Reviews {
+		if review.SubmittedAt == nil {
+			continue
+		}
+		if pr.FirstReviewed == nil || review.SubmittedAt.Before(*pr.FirstReviewed) {
+			pr.FirstReviewed = review.SubmittedAt
+		}
+	}
+	if pr.Created != nil {
+		if pr.FirstReviewed != nil {
+			pr.TimeToFirstReview = int64(pr.FirstReviewed.Sub(*pr.Created).Seconds())
+		}
+		if pr.MergedAt != nil {
+			pr.TimeToMerge = int64(pr.MergedAt.Sub(*pr.Created).Seconds())
+		}
+	}
+	return pr
+}
@@ -0,0 +1,64 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+
+	gh "github.com/nlamirault/geronimo/providers/github"
+)
+
+func TestNewPullRequest(t *testing.T) {
+	created := time.Date(2015, 12, 1, 10, 0, 0, 0, time.UTC)
+	reviewed := created.Add(2 * time.Hour)
+	merged := created.Add(24 * time.Hour)
+	repo := &github.Repository{Name: github.String("geronimo")}
+	pull := &gh.PullRequest{
+		PullRequest: github.PullRequest{
+			Number:    github.Int(7),
+			User:      &github.User{Login: github.String("foo")},
+			Merged:    github.Bool(true),
+			MergedBy:  &github.User{Login: github.String("nlamirault")},
+			CreatedAt: &created,
+			MergedAt:  &merged,
+			Base:      &github.PullRequestBranch{Ref: github.String("develop")},
+			Head:      &github.PullRequestBranch{Ref: github.String("feature")},
+			Additions: github.Int(10),
+		},
+		Draft: github.Bool(true),
+	}
+	late := reviewed.Add(time.Hour)
+	reviews := []gh.PullRequestReview{
+		gh.PullRequestReview{SubmittedAt: &late},
+		gh.PullRequestReview{SubmittedAt: &reviewed},
+	}
+	data := newPullRequest(repo, pullRequestData{pull, reviews})
+	if data.Number != 7 || data.Author != "foo" || !data.Merged ||
+		data.MergedBy != "nlamirault" || !data.Draft || data.Additions != 10 {
+		t.Fatalf("Invalid pull request: %#v", data)
+	}
+	if data.BaseBranch != "develop" || data.HeadBranch != "feature" {
+		t.Fatalf("Invalid pull request branches: %#v", data)
+	}
+	if data.Reviews != 2 || !data.FirstReviewed.Equal(reviewed) {
+		t.Fatalf("Invalid pull request reviews: %#v", data)
+	}
+	if data.TimeToFirstReview != 7200 || data.TimeToMerge != 86400 {
+		t.Fatalf("Invalid pull request timings: %#v", data)
+	}
+}
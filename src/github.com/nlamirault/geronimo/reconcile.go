@@ -0,0 +1,294 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+
+	gh "github.com/nlamirault/geronimo/providers/github"
+	"github.com/nlamirault/geronimo/storage"
+)
+
+// reconciliation is the result of the reconciliation of the repositories
+// stored for an owner with the repositories listed by GitHub.
+type reconciliation struct {
+	Owner string
+
+	// Renamed are the repositories renamed, as "old -> new".
+	Renamed []string
+
+	// Transferred are the repositories transferred to another owner, as
+	// "old -> owner/new".
+	Transferred []string
+
+	// Deleted are the repositories found deleted from GitHub.
+	Deleted []string
+
+	// Purged are the deleted repositories whose documents were removed.
+	Purged []string
+}
+
+// Log writes the summary of the reconciliation.
+func (r *reconciliation) Log() {
+	log.Printf("[INFO] Reconciliation of %s: %d renamed, %d transferred, %d deleted, %d purged",
+		r.Owner, len(r.Renamed), len(r.Transferred), len(r.Deleted), len(r.Purged))
+	for _, name := range r.Renamed {
+		log.Printf("[INFO] Repository renamed: %s", name)
+	}
+	for _, name := range r.Transferred {
+		log.Printf("[INFO] Repository transferred: %s", name)
+	}
+	for _, name := range r.Deleted {
+		log.Printf("[INFO] Repository deleted: %s", name)
+	}
+	for _, name := range r.Purged {
+		log.Printf("[INFO] Repository purged: %s", name)
+	}
+}
+
+// reconcileRepositories compares by ID the repositories stored for an owner
+// with the repositories listed by GitHub. The documents of a renamed
+// repository are moved under its new name, and the documents of a repository
+// transferred to another owner under its new owner. A deleted repository is
+// marked as deleted, and its documents are purged once the retention delay
+// has elapsed.
+func reconcileRepositories(client *github.Client, backend storage.Backend, owner string, repos []gh.Repository) (*reconciliation, error) {
+	owner = strings.ToLower(owner)
+	r := &reconciliation{Owner: owner}
+	listed := map[int]*github.Repository{}
+	for i := range repos {
+		if repos[i].ID != nil {
+			listed[*repos[i].ID] = &repos[i].Repository
+		}
+	}
+	stored, err := storedRepositories(backend, owner)
+	if err != nil {
+		return nil, fmt.Errorf("can't retrieve repositories: %s", err.Error())
+	}
+	for _, repo := range stored {
+		if repo.ID == 0 {
+			// Stored before the repositories were identified by ID
+			continue
+		}
+		if current, ok := listed[repo.ID]; ok {
+			if *current.Name != repo.Name {
+				if err := moveRepository(backend, repo, owner, *current.Name); err != nil {
+					return nil, err
+				}
+				r.Renamed = append(r.Renamed,
+					fmt.Sprintf("%s -> %s", repo.Name, *current.Name))
+			}
+			continue
+		}
+		if repo.Deleted != nil {
+			if !retentionExpired(*repo.Deleted) {
+				continue
+			}
+			if err := deleteRepository(backend, repo); err != nil {
+				return nil, err
+			}
+			r.Purged = append(r.Purged, repo.Name)
+			continue
+		}
+		current, resp, err := client.Repositories.Get(owner, repo.Name)
+		if err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound) {
+			return nil, fmt.Errorf("can't retrieve repository %s: %s",
+				repo.Name, err.Error())
+		}
+		switch {
+		case err == nil && intValue(current.ID) == repo.ID:
+			newOwner := strings.ToLower(userLogin(current.Owner))
+			if newOwner == owner {
+				log.Printf("[DEBUG] Repository %s exists but isn't listed",
+					repo.Name)
+				continue
+			}
+			if err := moveRepository(backend, repo, newOwner, *current.Name); err != nil {
+				return nil, err
+			}
+			r.Transferred = append(r.Transferred,
+				fmt.Sprintf("%s -> %s/%s", repo.Name, newOwner, *current.Name))
+		default:
+			// Not found, or the name is used by another repository
+			deleted := options.Date
+			repo.Deleted = &deleted
+			err := backend.Upsert(
+				options.Layout.Index("repository", owner, ""), "repository",
+				options.Layout.ID(owner, "", fmt.Sprintf("%d", repo.ID)), repo)
+			if err != nil {
+				return nil, fmt.Errorf("can't store repository %s: %s",
+					repo.Name, err.Error())
+			}
+			r.Deleted = append(r.Deleted, repo.Name)
+		}
+	}
+	return r, nil
+}
+
+// storedRepositories retrieves the repositories stored for an owner.
+func storedRepositories(backend storage.Backend, owner string) ([]storage.Repository, error) {
+	documents, err := backend.Query(
+		options.Layout.Index("repository", owner, ""), "repository",
+		map[string]interface{}{"owner": owner})
+	if err != nil {
+		return nil, err
+	}
+	var repos []storage.Repository
+	for _, document := range documents {
+		var repo storage.Repository
+		if err := json.Unmarshal(*document, &repo); err != nil {
+			return nil, err
+		}
+		repos = append(repos, repo)
+	}
+	return repos, nil
+}
+
+// retentionExpired returns true if the documents of a repository deleted at
+// a date must be purged.
+func retentionExpired(deleted time.Time) bool {
+	if options.DeletedRetention <= 0 {
+		return false
+	}
+	return !options.Date.Before(
+		deleted.AddDate(0, 0, options.DeletedRetention))
+}
+
+// moveRepository moves a repository and its documents to a new owner or
+// name: their owner and repository fields are rewritten, they are stored
+// under their new namespaces and IDs, then the previous documents are
+// removed.
+func moveRepository(backend storage.Backend, repo storage.Repository, owner string, name string) error {
+	for _, typename := range storage.RepositoryTypes() {
+		index := options.Layout.Index(typename, repo.Owner, repo.Name)
+		terms := options.Layout.Terms(typename, repo.Owner, repo.Name)
+		documents, err := backend.QueryDocuments(index, typename, terms)
+		if err != nil {
+			return fmt.Errorf("can't retrieve %s documents of %s: %s",
+				typename, repo.Name, err.Error())
+		}
+		if len(documents) == 0 {
+			continue
+		}
+		target := options.Layout.Index(typename, owner, name)
+		if err := backend.CreateNamespace(target); err != nil {
+			return fmt.Errorf("can't create namespace %s: %s",
+				target, err.Error())
+		}
+		for i, document := range documents {
+			body, err := movedDocument(document.Body.(*json.RawMessage),
+				storage.RepositoryFields(typename, owner, name))
+			if err != nil {
+				return fmt.Errorf("can't move %s document %s of %s: %s",
+					typename, document.ID, repo.Name, err.Error())
+			}
+			documents[i].ID = options.Layout.ID(owner, name,
+				options.Layout.LocalID(repo.Owner, repo.Name, document.ID))
+			documents[i].Body = body
+		}
+		if err := backend.BulkUpsert(target, documents); err != nil {
+			return fmt.Errorf("can't store %s documents of %s: %s",
+				typename, name, err.Error())
+		}
+		if err := backend.Flush(options.Layout.ID(owner, name, ""), target); err != nil {
+			return fmt.Errorf("can't store %s documents of %s: %s",
+				typename, name, err.Error())
+		}
+		if target == index && terms == nil {
+			// Only the case of the name changed: the documents were
+			// replaced
+			continue
+		}
+		count, err := backend.DeleteQuery(index, typename, terms)
+		if err != nil {
+			return fmt.Errorf("can't delete %s documents of %s: %s",
+				typename, repo.Name, err.Error())
+		}
+		log.Printf("[DEBUG] Repository %s: %d %s documents moved to %s/%s",
+			repo.Name, count, typename, owner, name)
+	}
+	moved := repo
+	moved.Owner = owner
+	moved.Name = name
+	index := options.Layout.Index("repository", owner, "")
+	id := options.Layout.ID(owner, "", fmt.Sprintf("%d", repo.ID))
+	if err := backend.CreateNamespace(index); err != nil {
+		return fmt.Errorf("can't create namespace %s: %s", index, err.Error())
+	}
+	if err := backend.Upsert(index, "repository", id, moved); err != nil {
+		return fmt.Errorf("can't store repository %s: %s", name, err.Error())
+	}
+	previous := options.Layout.Index("repository", repo.Owner, "")
+	previousID := options.Layout.ID(repo.Owner, "", fmt.Sprintf("%d", repo.ID))
+	if previous == index && previousID == id {
+		return nil
+	}
+	if err := backend.Delete(previous, "repository", previousID); err != nil {
+		return fmt.Errorf("can't delete repository %s: %s",
+			repo.Name, err.Error())
+	}
+	return nil
+}
+
+// movedDocument returns a document with its fields replaced.
+func movedDocument(document *json.RawMessage, fields map[string]interface{}) (map[string]interface{}, error) {
+	var body map[string]interface{}
+	if err := json.Unmarshal(*document, &body); err != nil {
+		return nil, err
+	}
+	for field, value := range fields {
+		body[field] = value
+	}
+	return body, nil
+}
+
+// purgeRepository removes the documents of a repository, except the
+// repository itself.
+func purgeRepository(backend storage.Backend, owner string, name string) error {
+	for _, typename := range storage.RepositoryTypes() {
+		count, err := backend.DeleteQuery(
+			options.Layout.Index(typename, owner, name), typename,
+			options.Layout.Terms(typename, owner, name))
+		if err != nil {
+			return fmt.Errorf("can't delete %s documents of %s: %s",
+				typename, name, err.Error())
+		}
+		log.Printf("[DEBUG] Repository %s: %d %s documents deleted",
+			name, count, typename)
+	}
+	return nil
+}
+
+// deleteRepository removes a repository and its documents.
+func deleteRepository(backend storage.Backend, repo storage.Repository) error {
+	if err := purgeRepository(backend, repo.Owner, repo.Name); err != nil {
+		return err
+	}
+	err := backend.Delete(
+		options.Layout.Index("repository", repo.Owner, ""), "repository",
+		options.Layout.ID(repo.Owner, "", fmt.Sprintf("%d", repo.ID)))
+	if err != nil {
+		return fmt.Errorf("can't delete repository %s: %s",
+			repo.Name, err.Error())
+	}
+	return nil
+}
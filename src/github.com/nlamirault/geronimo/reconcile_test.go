@@ -0,0 +1,146 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+
+	gh "github.com/nlamirault/geronimo/providers/github"
+	"github.com/nlamirault/geronimo/storage"
+)
+
+func storeTestRepository(t *testing.T, backend storage.Backend, repo storage.Repository) {
+	err := backend.Upsert("geronimo_repository", "repository",
+		fmt.Sprintf("nlamirault/%d", repo.ID), repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = backend.Upsert("geronimo_issue", "issue",
+		fmt.Sprintf("nlamirault/%s/1", repo.Name),
+		storage.Issue{Owner: "nlamirault", Repository: repo.Name, Number: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReconcileRepositories(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/nlamirault/moved":
+			fmt.Fprint(w, `{"id": 3, "name": "moved", "owner": {"login": "geronimo-org"}}`)
+		case "/repos/nlamirault/reused":
+			fmt.Fprint(w, `{"id": 40, "name": "reused", "owner": {"login": "nlamirault"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Not Found"}`)
+		}
+	}))
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	now := time.Date(2015, 12, 10, 0, 0, 0, 0, time.UTC)
+	expired := now.AddDate(0, 0, -31)
+	options.Layout = &storage.Layout{Strategy: storage.LayoutType}
+	options.Date = now
+	options.DeletedRetention = 30
+	backend := storage.NewMemory()
+	storeTestRepository(t, backend, storage.Repository{Owner: "nlamirault", ID: 1, Name: "geronimo"})
+	storeTestRepository(t, backend, storage.Repository{Owner: "nlamirault", ID: 2, Name: "old"})
+	storeTestRepository(t, backend, storage.Repository{Owner: "nlamirault", ID: 3, Name: "moved"})
+	storeTestRepository(t, backend, storage.Repository{Owner: "nlamirault", ID: 4, Name: "reused"})
+	storeTestRepository(t, backend, storage.Repository{Owner: "nlamirault", ID: 5, Name: "gone", Deleted: &expired})
+
+	id1, name1, id2, name2 := 1, "geronimo", 2, "new"
+	repos := []gh.Repository{
+		{Repository: github.Repository{ID: &id1, Name: &name1}},
+		{Repository: github.Repository{ID: &id2, Name: &name2}},
+	}
+	r, err := reconcileRepositories(client, backend, "NLamirault", repos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Renamed) != 1 || r.Renamed[0] != "old -> new" {
+		t.Fatalf("Invalid renamed repositories: %v", r.Renamed)
+	}
+	if len(r.Transferred) != 1 || r.Transferred[0] != "moved -> geronimo-org/moved" {
+		t.Fatalf("Invalid transferred repositories: %v", r.Transferred)
+	}
+	if len(r.Deleted) != 1 || r.Deleted[0] != "reused" {
+		t.Fatalf("Invalid deleted repositories: %v", r.Deleted)
+	}
+	if len(r.Purged) != 1 || r.Purged[0] != "gone" {
+		t.Fatalf("Invalid purged repositories: %v", r.Purged)
+	}
+
+	var repo storage.Repository
+	found, _ := backend.Get("geronimo_repository", "repository", "nlamirault/4", &repo)
+	if !found || repo.Deleted == nil || !repo.Deleted.Equal(now) {
+		t.Fatalf("Invalid deleted repository: %t %#v", found, repo)
+	}
+	for _, id := range []string{"nlamirault/3", "nlamirault/5"} {
+		if found, _ := backend.Get("geronimo_repository", "repository", id, &repo); found {
+			t.Fatalf("Invalid removed repository: %#v", repo)
+		}
+	}
+	found, _ = backend.Get("geronimo_repository", "repository", "nlamirault/2", &repo)
+	if !found || repo.Name != "new" {
+		t.Fatalf("Invalid renamed repository: %t %#v", found, repo)
+	}
+	found, _ = backend.Get("geronimo_repository", "repository", "geronimo-org/3", &repo)
+	if !found || repo.Owner != "geronimo-org" || repo.Name != "moved" {
+		t.Fatalf("Invalid transferred repository: %t %#v", found, repo)
+	}
+	for name, want := range map[string]int64{
+		"nlamirault/geronimo": 1, "nlamirault/old": 0, "nlamirault/new": 1,
+		"nlamirault/moved": 0, "geronimo-org/moved": 1,
+		"nlamirault/reused": 1, "nlamirault/gone": 0,
+	} {
+		parts := strings.Split(name, "/")
+		count, _ := backend.Count("geronimo_issue", "issue",
+			map[string]interface{}{"owner": parts[0], "repository": parts[1]})
+		if count != want {
+			t.Fatalf("Invalid issues of %s: %d", name, count)
+		}
+	}
+	var issue storage.Issue
+	found, _ = backend.Get("geronimo_issue", "issue", "nlamirault/new/1", &issue)
+	if !found || issue.Repository != "new" {
+		t.Fatalf("Invalid moved issue: %t %#v", found, issue)
+	}
+}
+
+func TestRetentionExpired(t *testing.T) {
+	options.Date = time.Date(2015, 12, 10, 0, 0, 0, 0, time.UTC)
+	options.DeletedRetention = 0
+	if retentionExpired(options.Date.AddDate(-1, 0, 0)) {
+		t.Fatalf("Invalid retention without delay")
+	}
+	options.DeletedRetention = 7
+	if retentionExpired(options.Date.AddDate(0, 0, -6)) {
+		t.Fatalf("Invalid retention before the delay")
+	}
+	if !retentionExpired(options.Date.AddDate(0, 0, -7)) {
+		t.Fatalf("Invalid retention after the delay")
+	}
+}
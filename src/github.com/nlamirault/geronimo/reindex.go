@@ -0,0 +1,84 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/nlamirault/geronimo/config"
+	"github.com/nlamirault/geronimo/storage"
+)
+
+// reindex rebuilds Elasticsearch indices. By default, the aliases given, or
+// the aliases of the indices using outdated mappings, are copied into new
+// indices. With fromGithub, all the indices are rebuilt by a complete
+// synchronization. The aliases are moved to the new indices once they are
+// complete, so the readers never see a partial index.
+func reindex(conf *config.Configuration, backend storage.Backend, fromGithub bool, aliases []string) error {
+	es, ok := backend.(storage.Reindexer)
+	if !ok {
+		return fmt.Errorf("reindex requires the elasticsearch storage backend")
+	}
+	if fromGithub {
+		es.Rebuild()
+		if err := synchronize(conf, backend, nil, nil); err != nil {
+			log.Printf("[WARN] Delete the rebuilt indices")
+			if err := es.DiscardRebuild(); err != nil {
+				log.Printf("[ERROR] Can't delete the rebuilt indices: %s", err.Error())
+			}
+			return fmt.Errorf("can't rebuild the indices: %s", err.Error())
+		}
+		return es.SwapAliases()
+	}
+	if len(aliases) == 0 {
+		var err error
+		aliases, err = outdatedAliases(es)
+		if err != nil {
+			return err
+		}
+		if len(aliases) == 0 {
+			log.Printf("[INFO] Indices are up to date")
+			return nil
+		}
+	}
+	for _, alias := range aliases {
+		if err := es.Reindex(alias); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// outdatedAliases returns the sorted aliases of the indices using outdated
+// mappings.
+func outdatedAliases(es storage.Reindexer) ([]string, error) {
+	outdated, err := es.OutdatedIndices()
+	if err != nil {
+		return nil, fmt.Errorf("can't check mappings: %s", err.Error())
+	}
+	seen := map[string]bool{}
+	var aliases []string
+	for index := range outdated {
+		alias := storage.AliasOf(index)
+		if !seen[alias] {
+			seen[alias] = true
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+	return aliases, nil
+}
@@ -0,0 +1,106 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+
+	"github.com/nlamirault/geronimo/config"
+	gh "github.com/nlamirault/geronimo/providers/github"
+	"github.com/nlamirault/geronimo/storage"
+)
+
+func TestReindexRequiresElasticsearch(t *testing.T) {
+	err := reindex(&config.Configuration{}, storage.NewMemory(), false, nil)
+	if err == nil {
+		t.Fatalf("Invalid reindex of the memory backend")
+	}
+}
+
+func TestRebuildOverPopulatedBackend(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch path := strings.TrimPrefix(r.URL.Path, "/repos/nlamirault/geronimo/"); {
+		case path == "stargazers":
+			json.NewEncoder(w).Encode([]gh.Stargazer{
+				newTestStargazer("user1"), newTestStargazer("user2"), newTestStargazer("user3"),
+			})
+		case path == "commits":
+			fmt.Fprint(w, `[{"sha": "c3"}, {"sha": "c2"}, {"sha": "c1"}]`)
+		default:
+			fmt.Fprintf(w, `{"sha": %q}`, strings.TrimPrefix(path, "commits/"))
+		}
+	}))
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	options = syncOptions{
+		From:    DefaultFrom,
+		PerPage: DefaultPerPage,
+		Layout:  &storage.Layout{Strategy: storage.LayoutType},
+		Types:   map[string]bool{"commit": true, "star": true},
+	}
+	defer func() { options.Rebuild = false }()
+	id, name, login := 42, "geronimo", "nlamirault"
+	repo := &github.Repository{ID: &id, Name: &name, Owner: &github.User{Login: &login}}
+
+	// The backend holds the stars, the newest commit and its checkpoint
+	backend := storage.NewMemory()
+	storeTestStars(t, backend, repo, "user1", "user2", "user3")
+	c3 := "c3"
+	err := saveCommits(backend, "nlamirault", repo,
+		[]*github.RepositoryCommit{{SHA: &c3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().AddDate(1, 0, 0)
+	checkpoints := repositoryCheckpoints{}
+	checkpoints.Update("commit", &future)
+	if err := saveCheckpoints(backend, "nlamirault", repo, checkpoints); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := fetchingRepository(client, backend, "nlamirault", repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Commits) != 2 || len(data.Stargazers) != 0 {
+		t.Fatalf("Invalid synchronization: %d commits %d stars",
+			len(data.Commits), len(data.Stargazers))
+	}
+
+	// A rebuild writes into empty indices: it retrieves everything
+	options.Rebuild = true
+	data, err = fetchingRepository(client, backend, "nlamirault", repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Commits) != 3 || len(data.Stargazers) != 3 || len(data.Unstarred) != 0 {
+		t.Fatalf("Invalid rebuild: %d commits %d stars",
+			len(data.Commits), len(data.Stargazers))
+	}
+	if since := data.Checkpoints.Since("commit"); since.After(time.Now()) {
+		t.Fatalf("Invalid rebuild checkpoint: %s", since)
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/go-github/github"
+
+	gh "github.com/nlamirault/geronimo/providers/github"
+	"github.com/nlamirault/geronimo/storage"
+)
+
+// retrieveRepositoryReleases retrieves the releases of a repository.
+func retrieveRepositoryReleases(client *github.Client, repo *github.Repository) ([]gh.RepositoryRelease, error) {
+	var releases []gh.RepositoryRelease
+	for page := options.From/options.PerPage + 1; page != 0; {
+		r, resp, err := gh.ListReleases(
+			client,
+			userLogin(repo.Owner),
+			*repo.Name,
+			&github.ListOptions{
+				PerPage: options.PerPage,
+				Page:    page,
+			})
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, r...)
+		page = nextPage(resp)
+	}
+	log.Printf("[DEBUG] Repository %s: %d releases", *repo.Name, len(releases))
+	return releases, nil
+}
+
+// retrieveRepositoryTags retrieves the tags of a repository.
+func retrieveRepositoryTags(client *github.Client, repo *github.Repository) ([]github.RepositoryTag, error) {
+	var tags []github.RepositoryTag
+	for page := options.From/options.PerPage + 1; page != 0; {
+		t, resp, err := client.Repositories.ListTags(
+			userLogin(repo.Owner),
+			*repo.Name,
+			&github.ListOptions{
+				PerPage: options.PerPage,
+				Page:    page,
+			})
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, t...)
+		page = nextPage(resp)
+	}
+	log.Printf("[DEBUG] Repository %s: %d tags", *repo.Name, len(tags))
+	return tags, nil
+}
+
+// saveReleases stores the releases and a snapshot of the download count of
+// each asset at the date of the synchronization.
+func saveReleases(backend storage.Backend, owner string, repo *github.Repository, releases []gh.RepositoryRelease) error {
+	index := options.Layout.Index("release", owner, *repo.Name)
+	assets := options.Layout.Index("asset_snapshot", owner, *repo.Name)
+	for _, release := range releases {
+		data := newRelease(repo, &release)
+		data.Owner = owner
+		err := backend.Upsert(index, "release",
+			options.Layout.ID(owner, *repo.Name, fmt.Sprintf("%d", data.ID)),
+			data)
+		if err != nil {
+			return fmt.Errorf("can't store release %s: %s",
+				data.Tag, err.Error())
+		}
+		for _, asset := range newAssetSnapshots(repo, &release, options.Date) {
+			asset.Owner = owner
+			err := backend.Upsert(assets, "asset_snapshot",
+				options.Layout.ID(owner, *repo.Name,
+					snapshotID(asset.ID, asset.Date)),
+				asset)
+			if err != nil {
+				return fmt.Errorf("can't store asset %s: %s",
+					asset.Name, err.Error())
+			}
+		}
+	}
+	log.Printf("[INFO] Indexed %d releases of %s to index %s",
+		len(releases), *repo.Name, index)
+	return nil
+}
+
+func saveTags(backend storage.Backend, owner string, repo *github.Repository, tags []github.RepositoryTag) error {
+	index := options.Layout.Index("tag", owner, *repo.Name)
+	for _, tag := range tags {
+		data := storage.Tag{
+			Owner:      owner,
+			Repository: *repo.Name,
+			Name:       stringValue(tag.Name),
+		}
+		if tag.Commit != nil {
+			data.SHA = stringValue(tag.Commit.SHA)
+		}
+		err := backend.Upsert(index, "tag",
+			options.Layout.ID(owner, *repo.Name, data.Name), data)
+		if err != nil {
+			return fmt.Errorf("can't store tag %s: %s",
+				data.Name, err.Error())
+		}
+	}
+	log.Printf("[INFO] Indexed %d tags of %s to index %s",
+		len(tags), *repo.Name, index)
+	return nil
+}
+
+func newRelease(repo *github.Repository, release *gh.RepositoryRelease) storage.Release {
+	data := storage.Release{
+		Repository: *repo.Name,
+		ID:         intValue(release.ID),
+		Tag:        stringValue(release.TagName),
+		Name:       stringValue(release.Name),
+		Author:     userLogin(release.Author),
+		Assets:     len(release.Assets),
+	}
+	if release.Draft != nil {
+		data.Draft = *release.Draft
+	}
+	if release.Prerelease != nil {
+		data.Prerelease = *release.Prerelease
+	}
+	if release.CreatedAt != nil {
+		data.Created = &release.CreatedAt.Time
+	}
+	if release.PublishedAt != nil {
+		data.Published = &release.PublishedAt.Time
+	}
+	for _, asset := range release.Assets {
+		data.Downloads += intValue(asset.DownloadCount)
+	}
+	return data
+}
+
+func newAssetSnapshots(repo *github.Repository, release *gh.RepositoryRelease, date time.Time) []storage.AssetSnapshot {
+	var snapshots []storage.AssetSnapshot
+	for _, asset := range release.Assets {
+		snapshots = append(snapshots, storage.AssetSnapshot{
+			Repository: *repo.Name,
+			Release:    stringValue(release.TagName),
+			ID:         intValue(asset.ID),
+			Name:       stringValue(asset.Name),
+			Downloads:  intValue(asset.DownloadCount),
+			Date:       date,
+		})
+	}
+	return snapshots
+}
+
+// snapshotID returns the identifier of the daily snapshot of an entity.
+func snapshotID(id int, date time.Time) string {
+	return fmt.Sprintf("%d-%s", id, date.UTC().Format("2006-01-02"))
+}
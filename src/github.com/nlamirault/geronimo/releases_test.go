@@ -0,0 +1,68 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+
+	gh "github.com/nlamirault/geronimo/providers/github"
+)
+
+func TestNewRelease(t *testing.T) {
+	repo := &github.Repository{Name: github.String("geronimo")}
+	release := &gh.RepositoryRelease{
+		RepositoryRelease: github.RepositoryRelease{
+			ID:         github.Int(1),
+			TagName:    github.String("v0.1.0"),
+			Prerelease: github.Bool(true),
+			Assets: []github.ReleaseAsset{
+				github.ReleaseAsset{
+					ID:            github.Int(10),
+					Name:          github.String("geronimo-linux"),
+					DownloadCount: github.Int(40),
+				},
+				github.ReleaseAsset{
+					ID:            github.Int(11),
+					Name:          github.String("geronimo-darwin"),
+					DownloadCount: github.Int(2),
+				},
+			},
+		},
+		Author: &github.User{Login: github.String("nlamirault")},
+	}
+	data := newRelease(repo, release)
+	if data.Tag != "v0.1.0" || !data.Prerelease || data.Draft ||
+		data.Author != "nlamirault" || data.Assets != 2 ||
+		data.Downloads != 42 {
+		t.Fatalf("Invalid release: %#v", data)
+	}
+
+	date := time.Date(2015, 12, 10, 18, 0, 0, 0, time.UTC)
+	snapshots := newAssetSnapshots(repo, release, date)
+	if len(snapshots) != 2 || snapshots[0].Downloads != 40 ||
+		snapshots[0].Release != "v0.1.0" || !snapshots[0].Date.Equal(date) {
+		t.Fatalf("Invalid asset snapshots: %#v", snapshots)
+	}
+}
+
+func TestSnapshotID(t *testing.T) {
+	date := time.Date(2015, 12, 10, 18, 0, 0, 0, time.UTC)
+	if id := snapshotID(42, date); id != "42-2015-12-10" {
+		t.Fatalf("Invalid snapshot id: %s", id)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nlamirault/geronimo/config"
+	"github.com/nlamirault/geronimo/storage"
+)
+
+// summaryTypes are the document types counted for each repository.
+var summaryTypes = []struct {
+	Type   string
+	Header string
+}{
+	{"issue", "ISSUES"},
+	{"pull_request", "PULL REQUESTS"},
+	{"commit", "COMMITS"},
+	{"contributor", "CONTRIBUTORS"},
+	{"release", "RELEASES"},
+	{"tag", "TAGS"},
+	{"star", "STARS"},
+}
+
+func reportCommand() *command {
+	cmd := newCommand("report", "", "Print a summary of the stored repositories.")
+	cmd.help = "The repositories of the users and organizations are listed with the\n" +
+		"number of documents of each type."
+	owners := cmd.flags.String("owners", "",
+		"Comma separated users and organizations (default all the configured ones)")
+	cmd.run = func(conf *config.Configuration, args []string) error {
+		if err := loadLayout(conf); err != nil {
+			return err
+		}
+		backend, err := openBackend(conf)
+		if err != nil {
+			return fmt.Errorf("can't open storage: %s", err.Error())
+		}
+		defer closeBackend(backend)
+		logins := configuredOwners(conf)
+		if *owners != "" {
+			logins = strings.Split(*owners, ",")
+		}
+		return printSummary(backend, logins, os.Stdout)
+	}
+	return cmd
+}
+
+// printSummary writes a table of the repositories stored for owners, with
+// the number of documents of each type.
+func printSummary(backend storage.Backend, owners []string, w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprint(tw, "REPOSITORY")
+	for _, t := range summaryTypes {
+		fmt.Fprintf(tw, "\t%s", t.Header)
+	}
+	fmt.Fprintln(tw, "\tDELETED")
+	for _, owner := range owners {
+		owner = strings.ToLower(owner)
+		repos, err := storedRepositories(backend, owner)
+		if err != nil {
+			return fmt.Errorf("can't retrieve repositories of %s: %s",
+				owner, err.Error())
+		}
+		for _, repo := range repos {
+			fmt.Fprintf(tw, "%s/%s", owner, repo.Name)
+			for _, t := range summaryTypes {
+				count, err := backend.Count(
+					options.Layout.Index(t.Type, owner, repo.Name), t.Type,
+					options.Layout.Terms(t.Type, owner, repo.Name))
+				if err != nil {
+					return fmt.Errorf("can't count %s documents of %s: %s",
+						t.Type, repo.Name, err.Error())
+				}
+				fmt.Fprintf(tw, "\t%d", count)
+			}
+			deleted := ""
+			if repo.Deleted != nil {
+				deleted = repo.Deleted.Format("2006-01-02")
+			}
+			fmt.Fprintf(tw, "\t%s\n", deleted)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,47 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlamirault/geronimo/storage"
+)
+
+func TestPrintSummary(t *testing.T) {
+	deleted := time.Date(2015, 12, 10, 0, 0, 0, 0, time.UTC)
+	options.Layout = &storage.Layout{Strategy: storage.LayoutType}
+	backend := storage.NewMemory()
+	storeTestRepository(t, backend, storage.Repository{Owner: "nlamirault", ID: 1, Name: "geronimo"})
+	storeTestRepository(t, backend, storage.Repository{Owner: "nlamirault", ID: 2, Name: "gone", Deleted: &deleted})
+
+	var b bytes.Buffer
+	if err := printSummary(backend, []string{"NLamirault"}, &b); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Invalid summary: %s", b.String())
+	}
+	if fields := strings.Fields(lines[1]); fields[0] != "nlamirault/geronimo" || fields[1] != "1" {
+		t.Fatalf("Invalid summary line: %v", fields)
+	}
+	if !strings.HasSuffix(lines[2], "2015-12-10") {
+		t.Fatalf("Invalid deleted repository line: %s", lines[2])
+	}
+}
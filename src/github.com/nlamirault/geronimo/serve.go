@@ -0,0 +1,116 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/nlamirault/geronimo/config"
+	"github.com/nlamirault/geronimo/storage"
+)
+
+// defaultListen is the default address of the HTTP server.
+const defaultListen = "localhost:8080"
+
+func serveCommand() *command {
+	cmd := newCommand("serve", "", "Serve the status of Geronimo over HTTP.")
+	cmd.help = "Endpoints:\n" +
+		"  /health        the status of the storage backend\n" +
+		"  /status        the status of the daemon\n" +
+		"  /repositories  the stored repositories, filtered by ?owner="
+	listen := cmd.flags.String("listen", defaultListen, "Address to listen on")
+	cmd.run = func(conf *config.Configuration, args []string) error {
+		if err := loadLayout(conf); err != nil {
+			return err
+		}
+		backend, err := openBackend(conf)
+		if err != nil {
+			return fmt.Errorf("can't open storage: %s", err.Error())
+		}
+		defer closeBackend(backend)
+		server := &http.Server{
+			Addr:    *listen,
+			Handler: newServeMux(conf, backend),
+		}
+		stop := notifyStop()
+		go func() {
+			<-stop
+			server.Close()
+		}()
+		log.Printf("[INFO] Listen on %s", *listen)
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	}
+	return cmd
+}
+
+// newServeMux returns the handlers of the HTTP server.
+func newServeMux(conf *config.Configuration, backend storage.Backend) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		info, err := backend.Ping()
+		if err != nil {
+			writeJSON(w, http.StatusServiceUnavailable,
+				map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"storage": info})
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		status, err := loadStatus(statusFile(conf))
+		switch {
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError,
+				map[string]string{"error": err.Error()})
+		case status == nil:
+			writeJSON(w, http.StatusNotFound,
+				map[string]string{"error": "the daemon never ran"})
+		default:
+			writeJSON(w, http.StatusOK, status)
+		}
+	})
+	mux.HandleFunc("/repositories", func(w http.ResponseWriter, r *http.Request) {
+		owners := configuredOwners(conf)
+		if owner := r.URL.Query().Get("owner"); owner != "" {
+			owners = []string{owner}
+		}
+		repos := []storage.Repository{}
+		for _, owner := range owners {
+			stored, err := storedRepositories(backend, strings.ToLower(owner))
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError,
+					map[string]string{"error": err.Error()})
+				return
+			}
+			repos = append(repos, stored...)
+		}
+		writeJSON(w, http.StatusOK, repos)
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[ERROR] Can't write response: %s", err.Error())
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nlamirault/geronimo/config"
+	"github.com/nlamirault/geronimo/storage"
+)
+
+func TestServeHealth(t *testing.T) {
+	mux := newServeMux(&config.Configuration{}, storage.NewMemory())
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Invalid health status: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestServeRepositories(t *testing.T) {
+	options.Layout = &storage.Layout{Strategy: storage.LayoutType}
+	backend := storage.NewMemory()
+	storeTestRepository(t, backend, storage.Repository{Owner: "nlamirault", ID: 1, Name: "geronimo"})
+	conf := &config.Configuration{}
+	conf.Github.Users = []string{"foo"}
+
+	mux := newServeMux(conf, backend)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/repositories?owner=NLamirault", nil))
+	var repos []storage.Repository
+	if err := json.Unmarshal(w.Body.Bytes(), &repos); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || len(repos) != 1 || repos[0].Name != "geronimo" {
+		t.Fatalf("Invalid repositories: %d %#v", w.Code, repos)
+	}
+}
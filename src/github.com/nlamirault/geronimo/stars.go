@@ -0,0 +1,194 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/go-github/github"
+
+	gh "github.com/nlamirault/geronimo/providers/github"
+	"github.com/nlamirault/geronimo/storage"
+)
+
+// storedStars returns the stars stored for a repository, from the oldest, or
+// none during a rebuild.
+func storedStars(backend storage.Backend, owner string, repo *github.Repository) ([]storage.Star, error) {
+	if options.Rebuild {
+		return nil, nil
+	}
+	documents, err := backend.Query(
+		options.Layout.Index("star", owner, *repo.Name), "star",
+		options.Layout.Terms("star", owner, *repo.Name))
+	if err != nil {
+		return nil, err
+	}
+	var stars []storage.Star
+	for _, document := range documents {
+		var star storage.Star
+		if err := json.Unmarshal(*document, &star); err != nil {
+			return nil, err
+		}
+		stars = append(stars, star)
+	}
+	sort.Sort(starsByDate(stars))
+	return stars, nil
+}
+
+type starsByDate []storage.Star
+
+func (s starsByDate) Len() int      { return len(s) }
+func (s starsByDate) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s starsByDate) Less(i, j int) bool {
+	ti, tj := timeValue(s[i].StarredAt), timeValue(s[j].StarredAt)
+	if !ti.Equal(tj) {
+		return ti.Before(tj)
+	}
+	return s[i].User < s[j].User
+}
+
+func timeValue(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+// sameStar returns true if a stargazer is the stored star.
+func sameStar(stargazer gh.Stargazer, star storage.Star) bool {
+	var starred time.Time
+	if stargazer.StarredAt != nil {
+		starred = stargazer.StarredAt.Time
+	}
+	return userLogin(stargazer.User) == star.User &&
+		starred.Equal(timeValue(star.StarredAt))
+}
+
+// listStargazers retrieves the stargazers of a repository from a page.
+func listStargazers(client *github.Client, repo *github.Repository, page int) ([]gh.Stargazer, error) {
+	var stargazers []gh.Stargazer
+	for page != 0 {
+		s, resp, err := gh.ListStargazers(
+			client,
+			userLogin(repo.Owner),
+			*repo.Name,
+			&github.ListOptions{
+				PerPage: options.PerPage,
+				Page:    page,
+			})
+		if err != nil {
+			return nil, err
+		}
+		stargazers = append(stargazers, s...)
+		page = nextPage(resp)
+	}
+	return stargazers, nil
+}
+
+// retrieveRepositoryStargazers retrieves the stargazers of a repository which
+// are not already stored, and the users who removed their star since the last
+// synchronization.
+// GitHub lists the stargazers from the oldest star. If the last stored star
+// is still at its position, no star was removed, so the retrieval starts from
+// its page. Otherwise, all the stargazers are retrieved and compared with the
+// stored stars.
+func retrieveRepositoryStargazers(client *github.Client, backend storage.Backend, username string, repo *github.Repository) ([]gh.Stargazer, []string, error) {
+	stored, err := storedStars(backend, username, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n := len(stored); n > 0 {
+		page := (n-1)/options.PerPage + 1
+		offset := (n - 1) % options.PerPage
+		stargazers, err := listStargazers(client, repo, page)
+		if err != nil {
+			return nil, nil, err
+		}
+		if offset < len(stargazers) && sameStar(stargazers[offset], stored[n-1]) {
+			stargazers = stargazers[offset+1:]
+			log.Printf("[DEBUG] Repository %s: %d new stargazers",
+				*repo.Name, len(stargazers))
+			return stargazers, nil, nil
+		}
+		log.Printf("[DEBUG] Repository %s: stars removed, retrieve all the stargazers",
+			*repo.Name)
+	}
+	all, err := listStargazers(client, repo, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	known := map[string]storage.Star{}
+	for _, star := range stored {
+		known[star.User] = star
+	}
+	listed := map[string]bool{}
+	var stargazers []gh.Stargazer
+	for _, stargazer := range all {
+		login := userLogin(stargazer.User)
+		listed[login] = true
+		if star, ok := known[login]; !ok || !sameStar(stargazer, star) {
+			stargazers = append(stargazers, stargazer)
+		}
+	}
+	var unstarred []string
+	for _, star := range stored {
+		if !listed[star.User] {
+			unstarred = append(unstarred, star.User)
+		}
+	}
+	log.Printf("[DEBUG] Repository %s: %d new stargazers, %d stars removed",
+		*repo.Name, len(stargazers), len(unstarred))
+	return stargazers, unstarred, nil
+}
+
+// saveStars stores the new stars of a repository, and deletes the stars
+// removed from GitHub.
+func saveStars(backend storage.Backend, owner string, repo *github.Repository, stargazers []gh.Stargazer, unstarred []string) error {
+	index := options.Layout.Index("star", owner, *repo.Name)
+	for _, user := range unstarred {
+		err := backend.Delete(index, "star", options.Layout.ID(owner, *repo.Name, user))
+		if err != nil {
+			return fmt.Errorf("can't delete star of %s: %s", user, err.Error())
+		}
+	}
+	for _, stargazer := range stargazers {
+		data := newStar(repo, stargazer)
+		data.Owner = owner
+		err := backend.Upsert(index, "star",
+			options.Layout.ID(owner, *repo.Name, data.User), data)
+		if err != nil {
+			return fmt.Errorf("can't store star of %s: %s",
+				data.User, err.Error())
+		}
+	}
+	log.Printf("[INFO] Indexed %d stars of %s to index %s, %d removed",
+		len(stargazers), *repo.Name, index, len(unstarred))
+	return nil
+}
+
+func newStar(repo *github.Repository, stargazer gh.Stargazer) storage.Star {
+	data := storage.Star{
+		Repository: *repo.Name,
+		User:       userLogin(stargazer.User),
+	}
+	if stargazer.StarredAt != nil {
+		data.StarredAt = &stargazer.StarredAt.Time
+	}
+	return data
+}
@@ -0,0 +1,133 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+
+	gh "github.com/nlamirault/geronimo/providers/github"
+	"github.com/nlamirault/geronimo/storage"
+)
+
+func TestNewStar(t *testing.T) {
+	date := time.Date(2015, 12, 10, 18, 0, 0, 0, time.UTC)
+	repo := &github.Repository{Name: github.String("geronimo")}
+	star := newStar(repo, gh.Stargazer{
+		StarredAt: &github.Timestamp{Time: date},
+		User:      &github.User{Login: github.String("foo")},
+	})
+	if star.Repository != "geronimo" || star.User != "foo" ||
+		!star.StarredAt.Equal(date) {
+		t.Fatalf("Invalid star: %#v", star)
+	}
+}
+
+// newTestStargazersServer serves the stargazers of users, starred a day
+// apart in this order, 2 per page.
+func newTestStargazersServer(users ...string) (*httptest.Server, *github.Client) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		var stargazers []gh.Stargazer
+		for i := (page - 1) * 2; i < len(users) && i < page*2; i++ {
+			stargazers = append(stargazers, newTestStargazer(users[i]))
+		}
+		if page*2 < len(users) {
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s%s?page=%d>; rel="next"`,
+				r.Host, r.URL.Path, page+1))
+		}
+		json.NewEncoder(w).Encode(stargazers)
+	}))
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	return server, client
+}
+
+func newTestStargazer(user string) gh.Stargazer {
+	date := time.Date(2015, 12, int(user[len(user)-1]-'0'), 0, 0, 0, 0, time.UTC)
+	return gh.Stargazer{
+		StarredAt: &github.Timestamp{Time: date},
+		User:      &github.User{Login: github.String(user)},
+	}
+}
+
+func storeTestStars(t *testing.T, backend storage.Backend, repo *github.Repository, users ...string) {
+	stargazers := []gh.Stargazer{}
+	for _, user := range users {
+		stargazers = append(stargazers, newTestStargazer(user))
+	}
+	if err := saveStars(backend, "nlamirault", repo, stargazers, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRetrieveNewStargazers(t *testing.T) {
+	server, client := newTestStargazersServer("user1", "user2", "user3", "user4")
+	defer server.Close()
+	options.Layout = &storage.Layout{Strategy: storage.LayoutType}
+	options.PerPage = 2
+	repo := &github.Repository{
+		Name:  github.String("geronimo"),
+		Owner: &github.User{Login: github.String("nlamirault")},
+	}
+	backend := storage.NewMemory()
+	storeTestStars(t, backend, repo, "user1", "user2", "user3")
+
+	stargazers, unstarred, err := retrieveRepositoryStargazers(client, backend, "nlamirault", repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stargazers) != 1 || userLogin(stargazers[0].User) != "user4" || len(unstarred) != 0 {
+		t.Fatalf("Invalid stargazers: %v %v", stargazers, unstarred)
+	}
+}
+
+func TestRetrieveRemovedStargazers(t *testing.T) {
+	// user2 removed its star and user4 starred: the count is the same
+	server, client := newTestStargazersServer("user1", "user3", "user4")
+	defer server.Close()
+	options.Layout = &storage.Layout{Strategy: storage.LayoutType}
+	options.PerPage = 2
+	repo := &github.Repository{
+		Name:  github.String("geronimo"),
+		Owner: &github.User{Login: github.String("nlamirault")},
+	}
+	backend := storage.NewMemory()
+	storeTestStars(t, backend, repo, "user1", "user2", "user3")
+
+	stargazers, unstarred, err := retrieveRepositoryStargazers(client, backend, "nlamirault", repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stargazers) != 1 || userLogin(stargazers[0].User) != "user4" ||
+		len(unstarred) != 1 || unstarred[0] != "user2" {
+		t.Fatalf("Invalid stargazers: %v %v", stargazers, unstarred)
+	}
+	if err := saveStars(backend, "nlamirault", repo, stargazers, unstarred); err != nil {
+		t.Fatal(err)
+	}
+	count, _ := backend.Count("geronimo_star", "star", nil)
+	if count != 3 {
+		t.Fatalf("Invalid stars count: %d", count)
+	}
+}
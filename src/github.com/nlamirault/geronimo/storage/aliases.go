@@ -0,0 +1,254 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/olivere/elastic.v3"
+)
+
+// generationSeparator separates the name of an alias from the generation of
+// the index behind it. It can't appear in GitHub names, so the index of an
+// alias never matches another alias.
+const generationSeparator = "@v"
+
+// GenerationIndex returns the name of the index of a generation of an alias.
+func GenerationIndex(alias string, generation int) string {
+	return fmt.Sprintf("%s%s%d", alias, generationSeparator, generation)
+}
+
+// AliasOf returns the alias of an index. The indices created before the
+// aliases are their own alias.
+func AliasOf(index string) string {
+	alias, _ := splitGeneration(index)
+	return alias
+}
+
+// splitGeneration returns the alias and the generation of an index, or the
+// index and 0 if it isn't behind an alias.
+func splitGeneration(index string) (string, int) {
+	i := strings.LastIndex(index, generationSeparator)
+	if i < 0 {
+		return index, 0
+	}
+	generation, err := strconv.Atoi(index[i+len(generationSeparator):])
+	if err != nil || generation < 1 {
+		return index, 0
+	}
+	return index[:i], generation
+}
+
+// aliasesState is the state of the indices and aliases of the cluster.
+type aliasesState struct {
+	// indices are the indices of each alias.
+	indices map[string][]string
+
+	// generations are the latest generations of each alias.
+	generations map[string]int
+
+	// concrete are the indices which are not behind an alias.
+	concrete map[string]bool
+}
+
+func (es *Elasticsearch) aliasesState() (*aliasesState, error) {
+	res, err := es.client.Aliases().Do()
+	if err != nil {
+		return nil, err
+	}
+	state := &aliasesState{
+		indices:     map[string][]string{},
+		generations: map[string]int{},
+		concrete:    map[string]bool{},
+	}
+	for index, result := range res.Indices {
+		alias, generation := splitGeneration(index)
+		if generation == 0 {
+			state.concrete[index] = true
+		} else if generation > state.generations[alias] {
+			state.generations[alias] = generation
+		}
+		for _, a := range result.Aliases {
+			state.indices[a.AliasName] = append(state.indices[a.AliasName], index)
+		}
+	}
+	return state, nil
+}
+
+// nextGeneration creates the index of the next generation of an alias,
+// without moving the alias.
+func (es *Elasticsearch) nextGeneration(alias string) (string, error) {
+	state, err := es.aliasesState()
+	if err != nil {
+		return "", err
+	}
+	index := GenerationIndex(alias, state.generations[alias]+1)
+	log.Printf("[INFO] Create index %s", index)
+	if _, err := es.client.CreateIndex(index).Do(); err != nil {
+		return "", err
+	}
+	return index, nil
+}
+
+// target returns the index where the documents of an alias are written and
+// read. During a rebuild, it creates the next index of the alias on first
+// use, so the documents are never read from the index being replaced.
+func (es *Elasticsearch) target(alias string) (string, error) {
+	// The lock is kept to create one index per alias
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if index, ok := es.targets[alias]; ok {
+		return index, nil
+	}
+	if !es.rebuild {
+		return alias, nil
+	}
+	index, err := es.nextGeneration(alias)
+	if err != nil {
+		return "", err
+	}
+	es.targets[alias] = index
+	return index, nil
+}
+
+// Rebuild makes the backend write into new indices instead of the indices
+// behind the aliases. The documents are read from the new indices too, so a
+// rebuild starts from empty indices. The aliases are moved to the new
+// indices by SwapAliases, or the new indices are deleted by DiscardRebuild.
+func (es *Elasticsearch) Rebuild() {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	es.rebuild = true
+}
+
+// Rebuilding returns true during a rebuild.
+func (es *Elasticsearch) Rebuilding() bool {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	return es.rebuild
+}
+
+// Reindex copies the documents of an alias into a new index, and moves the
+// alias to this index.
+func (es *Elasticsearch) Reindex(alias string) error {
+	index, err := es.nextGeneration(alias)
+	if err != nil {
+		return fmt.Errorf("can't create index: %s", err.Error())
+	}
+	log.Printf("[INFO] Reindex %s into %s", alias, index)
+	res, err := es.client.Reindex(alias, index).Do()
+	if err != nil {
+		es.deleteIndices(index)
+		return fmt.Errorf("can't reindex %s: %s", alias, err.Error())
+	}
+	if res.Failed > 0 {
+		es.deleteIndices(index)
+		return fmt.Errorf("can't reindex %d documents of %s", res.Failed, alias)
+	}
+	log.Printf("[INFO] %d documents reindexed into %s", res.Success, index)
+	es.mu.Lock()
+	es.targets[alias] = index
+	es.mu.Unlock()
+	return es.SwapAliases()
+}
+
+// SwapAliases moves the aliases to the new indices, in one atomic request,
+// then deletes their previous indices.
+// An index created before the aliases is replaced by an alias of the same
+// name once the aliases are moved: the index is deleted, then the alias is
+// added to the new index, which already holds the documents. Elasticsearch
+// can't do both atomically, so the alias is missing in between.
+func (es *Elasticsearch) SwapAliases() error {
+	es.bulk.Flush()
+	es.mu.Lock()
+	targets := es.targets
+	es.targets = map[string]string{}
+	es.rebuild = false
+	es.mu.Unlock()
+	if len(targets) == 0 {
+		return nil
+	}
+	state, err := es.aliasesState()
+	if err != nil {
+		return err
+	}
+	var previous, concrete []string
+	swap := es.client.Alias()
+	actions := 0
+	for alias, index := range targets {
+		if state.concrete[alias] {
+			concrete = append(concrete, alias)
+			continue
+		}
+		for _, old := range state.indices[alias] {
+			swap.Remove(old, alias)
+			previous = append(previous, old)
+		}
+		swap.Add(index, alias)
+		actions++
+		log.Printf("[INFO] Move alias %s to %s", alias, index)
+	}
+	if actions > 0 {
+		if _, err := swap.Do(); err != nil {
+			return fmt.Errorf("can't move aliases: %s", err.Error())
+		}
+	}
+	if err := es.deleteIndices(previous...); err != nil {
+		return err
+	}
+	sort.Strings(concrete)
+	for _, alias := range concrete {
+		index := targets[alias]
+		log.Printf("[WARN] Replace index %s with an alias of %s", alias, index)
+		if err := es.deleteIndices(alias); err != nil {
+			return err
+		}
+		if _, err := es.client.Alias().Add(index, alias).Do(); err != nil {
+			return fmt.Errorf("can't add alias %s, its documents are in index %s: %s",
+				alias, index, err.Error())
+		}
+	}
+	return nil
+}
+
+// DiscardRebuild deletes the new indices of a rebuild.
+func (es *Elasticsearch) DiscardRebuild() error {
+	es.mu.Lock()
+	targets := es.targets
+	es.targets = map[string]string{}
+	es.rebuild = false
+	es.mu.Unlock()
+	var indices []string
+	for _, index := range targets {
+		indices = append(indices, index)
+	}
+	return es.deleteIndices(indices...)
+}
+
+func (es *Elasticsearch) deleteIndices(indices ...string) error {
+	if len(indices) == 0 {
+		return nil
+	}
+	log.Printf("[INFO] Delete indices %v", indices)
+	_, err := es.client.DeleteIndex(indices...).Do()
+	if err != nil && !elastic.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestGenerations(t *testing.T) {
+	if index := GenerationIndex("nlamirault_geronimo", 2); index != "nlamirault_geronimo@v2" {
+		t.Fatalf("Invalid generation index: %s", index)
+	}
+	for index, alias := range map[string]string{
+		"nlamirault_geronimo@v2": "nlamirault_geronimo",
+		"nlamirault_geronimo":    "nlamirault_geronimo",
+		"nlamirault_v1":          "nlamirault_v1",
+		"nlamirault@vfoo":        "nlamirault@vfoo",
+	} {
+		if a := AliasOf(index); a != alias {
+			t.Fatalf("Invalid alias of %s: %s", index, a)
+		}
+	}
+}
+
+// fakeCluster is a fake Elasticsearch cluster managing indices and aliases.
+type fakeCluster struct {
+	mu      sync.Mutex
+	indices map[string][]string
+	actions []string
+	deleted []string
+	// failAliases rejects the aliases requests
+	failAliases bool
+}
+
+func (c *fakeCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	name := strings.Trim(r.URL.Path, "/")
+	switch {
+	case r.Method == "GET" && name == "_aliases":
+		res := map[string]interface{}{}
+		for index, aliases := range c.indices {
+			m := map[string]interface{}{}
+			for _, alias := range aliases {
+				m[alias] = map[string]interface{}{}
+			}
+			res[index] = map[string]interface{}{"aliases": m}
+		}
+		json.NewEncoder(w).Encode(res)
+	case r.Method == "POST" && name == "_aliases" && c.failAliases:
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `{"error":"failed"}`)
+	case r.Method == "POST" && name == "_aliases":
+		var body struct {
+			Actions []map[string]map[string]string `json:"actions"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		for _, action := range body.Actions {
+			for typ, details := range action {
+				c.actions = append(c.actions,
+					fmt.Sprintf("%s %s %s", typ, details["index"], details["alias"]))
+			}
+		}
+		fmt.Fprint(w, `{"acknowledged":true}`)
+	case r.Method == "HEAD":
+		for index, aliases := range c.indices {
+			if index == name {
+				return
+			}
+			for _, alias := range aliases {
+				if alias == name {
+					return
+				}
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == "PUT":
+		var body struct {
+			Aliases map[string]interface{} `json:"aliases"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		c.indices[name] = []string{}
+		for alias := range body.Aliases {
+			c.indices[name] = append(c.indices[name], alias)
+		}
+		fmt.Fprint(w, `{"acknowledged":true}`)
+	case r.Method == "DELETE":
+		for _, index := range strings.Split(name, ",") {
+			delete(c.indices, index)
+			c.deleted = append(c.deleted, index)
+		}
+		fmt.Fprint(w, `{"acknowledged":true}`)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestElasticsearchReindexer(t *testing.T) {
+	var backend Backend = &Elasticsearch{}
+	if _, ok := backend.(Reindexer); !ok {
+		t.Fatalf("Invalid Elasticsearch backend: not a Reindexer")
+	}
+	if _, ok := Backend(NewMemory()).(Rebuilder); ok {
+		t.Fatalf("Invalid memory backend: a Rebuilder")
+	}
+}
+
+func TestCreateNamespaceWithAlias(t *testing.T) {
+	cluster := &fakeCluster{indices: map[string][]string{
+		"nlamirault": {},
+	}}
+	server, es := newTestElasticsearch(t, cluster.ServeHTTP)
+	defer server.Close()
+	defer es.Close()
+
+	for _, alias := range []string{"nlamirault_geronimo", "nlamirault"} {
+		if err := es.CreateNamespace(alias); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(cluster.indices) != 2 ||
+		!reflect.DeepEqual(cluster.indices["nlamirault_geronimo@v1"], []string{"nlamirault_geronimo"}) {
+		t.Fatalf("Invalid indices: %v", cluster.indices)
+	}
+}
+
+func TestRebuildAndSwapAliases(t *testing.T) {
+	cluster := &fakeCluster{indices: map[string][]string{
+		"nlamirault_geronimo@v1": {"nlamirault_geronimo"},
+		"nlamirault":             {},
+	}}
+	server, es := newTestElasticsearch(t, cluster.ServeHTTP)
+	defer server.Close()
+	defer es.Close()
+
+	es.Rebuild()
+	for _, alias := range []string{"nlamirault_geronimo", "nlamirault", "nlamirault"} {
+		if err := es.CreateNamespace(alias); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if target, _ := es.target("nlamirault_geronimo"); target != "nlamirault_geronimo@v2" {
+		t.Fatalf("Invalid rebuild target: %s", target)
+	}
+	if target, _ := es.target("nlamirault"); target != "nlamirault@v1" {
+		t.Fatalf("Invalid rebuild target: %s", target)
+	}
+	if err := es.SwapAliases(); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(cluster.actions)
+	expected := []string{
+		"add nlamirault@v1 nlamirault",
+		"add nlamirault_geronimo@v2 nlamirault_geronimo",
+		"remove nlamirault_geronimo@v1 nlamirault_geronimo",
+	}
+	if !reflect.DeepEqual(cluster.actions, expected) {
+		t.Fatalf("Invalid aliases actions: %v", cluster.actions)
+	}
+	sort.Strings(cluster.deleted)
+	if !reflect.DeepEqual(cluster.deleted, []string{"nlamirault", "nlamirault_geronimo@v1"}) {
+		t.Fatalf("Invalid deleted indices: %v", cluster.deleted)
+	}
+	if target, _ := es.target("nlamirault_geronimo"); target != "nlamirault_geronimo" {
+		t.Fatalf("Invalid target after swap: %s", target)
+	}
+}
+
+func TestRebuildReadsNewIndex(t *testing.T) {
+	cluster := &fakeCluster{indices: map[string][]string{
+		"nlamirault_geronimo@v1": {"nlamirault_geronimo"},
+	}}
+	server, es := newTestElasticsearch(t, cluster.ServeHTTP)
+	defer server.Close()
+	defer es.Close()
+
+	es.Rebuild()
+	// Read before CreateNamespace, as the fetchers do
+	target, err := es.target("nlamirault_geronimo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != "nlamirault_geronimo@v2" || len(cluster.indices) != 2 {
+		t.Fatalf("Invalid read target: %s %v", target, cluster.indices)
+	}
+	if err := es.CreateNamespace("nlamirault_geronimo"); err != nil {
+		t.Fatal(err)
+	}
+	if len(cluster.indices) != 2 {
+		t.Fatalf("Invalid indices: %v", cluster.indices)
+	}
+}
+
+func TestSwapAliasesFailure(t *testing.T) {
+	cluster := &fakeCluster{
+		indices: map[string][]string{
+			"nlamirault_geronimo@v1": {"nlamirault_geronimo"},
+			"nlamirault":             {},
+		},
+		failAliases: true,
+	}
+	server, es := newTestElasticsearch(t, cluster.ServeHTTP)
+	defer server.Close()
+	defer es.Close()
+
+	es.Rebuild()
+	for _, alias := range []string{"nlamirault_geronimo", "nlamirault"} {
+		if err := es.CreateNamespace(alias); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := es.SwapAliases(); err == nil {
+		t.Fatalf("Aliases moved")
+	}
+	// The previous indices, including the one created before the aliases,
+	// are kept
+	if len(cluster.deleted) != 0 {
+		t.Fatalf("Invalid deleted indices: %v", cluster.deleted)
+	}
+}
+
+func TestDiscardRebuild(t *testing.T) {
+	cluster := &fakeCluster{indices: map[string][]string{
+		"nlamirault_geronimo@v1": {"nlamirault_geronimo"},
+	}}
+	server, es := newTestElasticsearch(t, cluster.ServeHTTP)
+	defer server.Close()
+	defer es.Close()
+
+	es.Rebuild()
+	if err := es.CreateNamespace("nlamirault_geronimo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := es.DiscardRebuild(); err != nil {
+		t.Fatal(err)
+	}
+	if len(cluster.indices) != 1 || len(cluster.actions) != 0 {
+		t.Fatalf("Invalid discarded rebuild: %v %v", cluster.indices, cluster.actions)
+	}
+}
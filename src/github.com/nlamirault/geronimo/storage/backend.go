@@ -0,0 +1,167 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/nlamirault/geronimo/config"
+)
+
+// DefaultBackend is the backend used when none is configured.
+const DefaultBackend = "elasticsearch"
+
+// Document is a document stored with a bulk upsert.
+type Document struct {
+	Type string
+	ID   string
+	Body interface{}
+}
+
+// Backend stores the documents collected by Geronimo. Documents are
+// identified by a namespace (an index for Elasticsearch), a type and an ID.
+type Backend interface {
+	// Ping checks the backend is reachable and returns its description.
+	Ping() (string, error)
+
+	// CreateNamespace creates a namespace if it doesn't exist.
+	CreateNamespace(name string) error
+
+	// Upsert creates or replaces a document.
+	Upsert(namespace string, typename string, id string, body interface{}) error
+
+	// BulkUpsert creates or replaces several documents of a namespace.
+	BulkUpsert(namespace string, documents []Document) error
+
+	// Get retrieves a document and decodes it into v. It returns false if
+	// the document doesn't exist.
+	Get(namespace string, typename string, id string, v interface{}) (bool, error)
+
+	// Count returns the number of documents of a type whose fields are equal
+	// to the terms.
+	Count(namespace string, typename string, terms map[string]interface{}) (int64, error)
+
+	// Query retrieves the documents of a type whose fields are equal to the
+	// terms. All the documents of the type are returned if terms is empty.
+	Query(namespace string, typename string, terms map[string]interface{}) ([]*json.RawMessage, error)
+
+	// QueryDocuments retrieves the documents like Query, with their IDs.
+	// Their bodies are *json.RawMessage.
+	QueryDocuments(namespace string, typename string, terms map[string]interface{}) ([]Document, error)
+
+	// Delete removes a document. Deleting a missing document isn't an error.
+	Delete(namespace string, typename string, id string) error
+
+	// DeleteQuery removes the documents of a type whose fields are equal to
+	// the terms, and returns their number.
+	DeleteQuery(namespace string, typename string, terms map[string]interface{}) (int64, error)
+
+	// Flush waits until the pending writes are done. It returns a *BulkError
+	// listing the documents of the namespaces, or of all the namespaces if
+	// none is given, whose ID starts with prefix, which couldn't be stored
+	// since the last flush. The prefix selects the documents of a
+	// repository, see Layout.ID, when its namespaces are shared.
+	Flush(prefix string, namespaces ...string) error
+}
+
+// Rebuilder is implemented by the backends which can rebuild their
+// namespaces from empty ones, like the Elasticsearch indices behind aliases.
+type Rebuilder interface {
+	// Rebuilding returns true while the namespaces are rebuilt.
+	Rebuilding() bool
+}
+
+// Reindexer is implemented by the backends whose namespaces can be rebuilt
+// or reindexed without interrupting the readers.
+type Reindexer interface {
+	Rebuilder
+
+	// Rebuild makes the backend write into new namespaces.
+	Rebuild()
+
+	// SwapAliases makes the readers use the rebuilt namespaces.
+	SwapAliases() error
+
+	// DiscardRebuild deletes the rebuilt namespaces.
+	DiscardRebuild() error
+
+	// Reindex copies the documents of an alias into a new namespace.
+	Reindex(alias string) error
+
+	// OutdatedIndices returns the namespaces mapped with a previous version
+	// of the mappings, and this version.
+	OutdatedIndices() (map[string]int, error)
+}
+
+// bodies returns the bodies of the documents retrieved by QueryDocuments.
+func bodies(documents []Document) []*json.RawMessage {
+	var raws []*json.RawMessage
+	for _, document := range documents {
+		raws = append(raws, document.Body.(*json.RawMessage))
+	}
+	return raws
+}
+
+// Factory creates a backend from the configuration.
+type Factory func(conf *config.Configuration) (Backend, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]Factory{}
+)
+
+// Register makes a backend available by name. It panics if the name is
+// already registered.
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("storage: backend %s registered twice", name))
+	}
+	factories[name] = factory
+}
+
+// Backends returns the sorted names of the registered backends.
+func Backends() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	var names []string
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates the backend selected by the configuration.
+func New(conf *config.Configuration) (Backend, error) {
+	name := conf.Storage.Backend
+	if name == "" {
+		name = DefaultBackend
+	}
+	factoriesMu.RLock()
+	factory, ok := factories[name]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown storage backend %s (available: %v)",
+			name, Backends())
+	}
+	log.Printf("[DEBUG] Storage backend: %s", name)
+	return factory(conf)
+}
@@ -0,0 +1,494 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/olivere/elastic.v3"
+)
+
+const (
+	// DefaultBulkActions is the default number of documents of a bulk
+	// request.
+	DefaultBulkActions = 500
+
+	// DefaultBulkFlushInterval is the default delay after which the pending
+	// documents are sent, even if the bulk request isn't full.
+	DefaultBulkFlushInterval = 5 * time.Second
+
+	// DefaultBulkWorkers is the default number of bulk requests sent in
+	// parallel.
+	DefaultBulkWorkers = 2
+
+	// DefaultBulkMaxRetries is the default number of retries of the
+	// documents rejected because Elasticsearch is overloaded.
+	DefaultBulkMaxRetries = 5
+
+	// DefaultBulkBackoff is the initial delay before retrying rejected
+	// documents. It is doubled on each retry.
+	DefaultBulkBackoff = time.Second
+)
+
+// ErrBulkProcessorClosed is returned when a document is added to a closed
+// BulkProcessor.
+var ErrBulkProcessorClosed = errors.New("bulk processor closed")
+
+// BulkFailure is a document which couldn't be indexed.
+type BulkFailure struct {
+	Index  string
+	Type   string
+	ID     string
+	Status int
+	Reason string
+}
+
+func (f BulkFailure) Error() string {
+	return fmt.Sprintf("can't index %s %s into %s: %d %s",
+		f.Type, f.ID, f.Index, f.Status, f.Reason)
+}
+
+// BulkError is the list of the documents which couldn't be indexed.
+type BulkError struct {
+	Failures []BulkFailure
+}
+
+func (e *BulkError) Error() string {
+	return fmt.Sprintf("%d documents not indexed, first error: %s",
+		len(e.Failures), e.Failures[0].Error())
+}
+
+// BulkStats are the statistics of a BulkProcessor.
+type BulkStats struct {
+	Requests int
+	Indexed  int
+	Retried  int
+	Failed   int
+}
+
+type bulkItem struct {
+	index    string
+	typename string
+	id       string
+	body     interface{}
+}
+
+// BulkProcessor indexes documents with bulk requests. Documents are sent
+// when Actions documents are pending, every FlushInterval, or on Flush and
+// FlushIndices. The documents rejected with a 429 status (Elasticsearch
+// queues full) are retried with an exponential backoff; the other rejected
+// documents are kept as failures, retrieved by index with Failures. A
+// BulkProcessor is safe for concurrent use.
+type BulkProcessor struct {
+	// Actions is the maximum number of documents of a bulk request.
+	Actions int
+
+	// FlushInterval is the delay after which pending documents are sent.
+	// Zero disables the periodic flush.
+	FlushInterval time.Duration
+
+	// Workers is the number of bulk requests sent in parallel.
+	Workers int
+
+	// MaxRetries is the number of retries of a rejected document.
+	MaxRetries int
+
+	// Backoff is the initial delay between retries.
+	Backoff time.Duration
+
+	client *elastic.Client
+	sleep  func(time.Duration)
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	buffer   []bulkItem
+	pending  int
+	failures map[string][]BulkFailure
+	stats    BulkStats
+	closed   bool
+	// queued counts the documents of each index not indexed yet
+	queued map[string]int
+
+	batches chan []bulkItem
+	done    chan struct{}
+	// senders counts the batches taken from the buffer and not yet sent,
+	// so Close waits for them before closing batches
+	senders sync.WaitGroup
+	flushed sync.WaitGroup
+	wg      sync.WaitGroup
+}
+
+// NewBulkProcessor creates a new BulkProcessor with the default settings.
+// The settings can be changed before calling Start.
+func NewBulkProcessor(client *elastic.Client) *BulkProcessor {
+	p := &BulkProcessor{
+		Actions:       DefaultBulkActions,
+		FlushInterval: DefaultBulkFlushInterval,
+		Workers:       DefaultBulkWorkers,
+		MaxRetries:    DefaultBulkMaxRetries,
+		Backoff:       DefaultBulkBackoff,
+		client:        client,
+		sleep:         time.Sleep,
+		queued:        map[string]int{},
+		failures:      map[string][]BulkFailure{},
+	}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// Start launches the workers and the periodic flush.
+func (p *BulkProcessor) Start() {
+	if p.Workers < 1 {
+		p.Workers = 1
+	}
+	p.batches = make(chan []bulkItem, p.Workers)
+	p.done = make(chan struct{})
+	for i := 0; i < p.Workers; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+	if p.FlushInterval > 0 {
+		p.flushed.Add(1)
+		go p.flusher()
+	}
+}
+
+// Add queues a document.
+func (p *BulkProcessor) Add(index string, typename string, id string,
+	body interface{}) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrBulkProcessorClosed
+	}
+	p.buffer = append(p.buffer, bulkItem{
+		index:    index,
+		typename: typename,
+		id:       id,
+		body:     body,
+	})
+	p.queued[index]++
+	var batch []bulkItem
+	if len(p.buffer) >= p.Actions {
+		batch = p.takeLocked()
+	}
+	p.mu.Unlock()
+	p.send(batch)
+	return nil
+}
+
+// takeLocked empties the buffer and returns its documents, which must be
+// given to send. p.mu must be held.
+func (p *BulkProcessor) takeLocked() []bulkItem {
+	batch := p.buffer
+	p.buffer = nil
+	return p.batchLocked(batch)
+}
+
+// takeIndicesLocked removes the documents of the indices from the buffer
+// and returns them, like takeLocked. p.mu must be held.
+func (p *BulkProcessor) takeIndicesLocked(indices []string) []bulkItem {
+	var batch, kept []bulkItem
+	for _, item := range p.buffer {
+		if containsIndex(indices, item.index) {
+			batch = append(batch, item)
+		} else {
+			kept = append(kept, item)
+		}
+	}
+	p.buffer = kept
+	return p.batchLocked(batch)
+}
+
+// batchLocked counts a batch taken from the buffer until it is sent.
+// p.mu must be held.
+func (p *BulkProcessor) batchLocked(batch []bulkItem) []bulkItem {
+	if len(batch) > 0 {
+		p.pending++
+		p.senders.Add(1)
+	}
+	return batch
+}
+
+func (p *BulkProcessor) send(batch []bulkItem) {
+	if len(batch) > 0 {
+		p.batches <- batch
+		p.senders.Done()
+	}
+}
+
+// Flush sends the pending documents and waits until all the bulk requests
+// are done.
+func (p *BulkProcessor) Flush() {
+	p.mu.Lock()
+	batch := p.takeLocked()
+	p.mu.Unlock()
+	p.send(batch)
+
+	p.mu.Lock()
+	for p.pending > 0 {
+		p.cond.Wait()
+	}
+	p.mu.Unlock()
+}
+
+// FlushIndices sends the pending documents of the indices and waits until
+// they are indexed. The documents of the other indices stay pending, and
+// nothing is sent if the indices have no pending document.
+func (p *BulkProcessor) FlushIndices(indices ...string) {
+	p.mu.Lock()
+	if !p.queuedLocked(indices) {
+		p.mu.Unlock()
+		return
+	}
+	batch := p.takeIndicesLocked(indices)
+	p.mu.Unlock()
+	p.send(batch)
+
+	p.mu.Lock()
+	for p.queuedLocked(indices) {
+		p.cond.Wait()
+	}
+	p.mu.Unlock()
+}
+
+// queuedLocked returns true if documents of the indices aren't indexed yet.
+// p.mu must be held.
+func (p *BulkProcessor) queuedLocked(indices []string) bool {
+	for _, index := range indices {
+		if p.queued[index] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func containsIndex(indices []string, index string) bool {
+	for _, i := range indices {
+		if i == index {
+			return true
+		}
+	}
+	return false
+}
+
+// Failures returns and forgets the failures of the documents whose ID
+// starts with prefix, from the indices, or from all the indices if none is
+// given. The failures of the other documents are kept for their writers.
+func (p *BulkProcessor) Failures(prefix string, indices ...string) []BulkFailure {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(indices) == 0 {
+		for index := range p.failures {
+			indices = append(indices, index)
+		}
+	}
+	var failures []BulkFailure
+	for _, index := range indices {
+		var kept []BulkFailure
+		for _, failure := range p.failures[index] {
+			if strings.HasPrefix(failure.ID, prefix) {
+				failures = append(failures, failure)
+			} else {
+				kept = append(kept, failure)
+			}
+		}
+		if len(kept) > 0 {
+			p.failures[index] = kept
+		} else {
+			delete(p.failures, index)
+		}
+	}
+	return failures
+}
+
+// Stats returns the statistics of the processor.
+func (p *BulkProcessor) Stats() BulkStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
+
+// LogStats writes the statistics of the processor.
+func (p *BulkProcessor) LogStats() {
+	stats := p.Stats()
+	log.Printf("[INFO] Elasticsearch: %d bulk requests, %d documents indexed, %d retried, %d failed",
+		stats.Requests, stats.Indexed, stats.Retried, stats.Failed)
+}
+
+// Close sends the pending documents and stops the workers. Once closed, no
+// batch can be taken from the buffer, so the batches channel is closed when
+// the flusher is stopped and the batches already taken are sent.
+func (p *BulkProcessor) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	batch := p.takeLocked()
+	p.mu.Unlock()
+	close(p.done)
+	p.flushed.Wait()
+	p.send(batch)
+	p.senders.Wait()
+	close(p.batches)
+	p.wg.Wait()
+	return nil
+}
+
+func (p *BulkProcessor) flusher() {
+	defer p.flushed.Done()
+	ticker := time.NewTicker(p.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.mu.Lock()
+			batch := p.takeLocked()
+			p.mu.Unlock()
+			p.send(batch)
+		case <-p.done:
+			return
+		}
+	}
+}
+
+func (p *BulkProcessor) worker() {
+	defer p.wg.Done()
+	for batch := range p.batches {
+		p.commit(batch)
+		p.mu.Lock()
+		p.pending--
+		for _, item := range batch {
+			if p.queued[item.index]--; p.queued[item.index] == 0 {
+				delete(p.queued, item.index)
+			}
+		}
+		p.cond.Broadcast()
+		p.mu.Unlock()
+	}
+}
+
+// commit indexes a batch, retrying the documents rejected with a 429 status.
+func (p *BulkProcessor) commit(batch []bulkItem) {
+	backoff := p.Backoff
+	for retry := 0; len(batch) > 0; retry++ {
+		rejected, failures, indexed := p.do(batch)
+		canRetry := retry < p.MaxRetries
+		p.mu.Lock()
+		p.stats.Requests++
+		p.stats.Indexed += indexed
+		if canRetry {
+			p.stats.Retried += len(rejected)
+		} else {
+			failures = append(failures, rejected...)
+		}
+		p.stats.Failed += len(failures)
+		for _, failure := range failures {
+			p.failures[failure.Index] = append(p.failures[failure.Index], failure)
+		}
+		p.mu.Unlock()
+		if !canRetry || len(rejected) == 0 {
+			return
+		}
+		log.Printf("[WARN] Elasticsearch rejected %d documents, retry in %s",
+			len(rejected), backoff)
+		p.sleep(backoff)
+		backoff *= 2
+		retried := make([]bulkItem, 0, len(rejected))
+		for _, failure := range rejected {
+			for _, item := range batch {
+				if item.index == failure.Index && item.typename == failure.Type &&
+					item.id == failure.ID {
+					retried = append(retried, item)
+					break
+				}
+			}
+		}
+		batch = retried
+	}
+}
+
+// do sends a bulk request. It returns the documents to retry, the failures
+// and the number of documents indexed.
+func (p *BulkProcessor) do(batch []bulkItem) ([]BulkFailure, []BulkFailure, int) {
+	bulk := p.client.Bulk()
+	for _, item := range batch {
+		bulk.Add(elastic.NewBulkIndexRequest().
+			Index(item.index).
+			Type(item.typename).
+			Id(item.id).
+			Doc(item.body))
+	}
+	var rejected, failures []BulkFailure
+	res, err := bulk.Do()
+	if err != nil {
+		status := 0
+		if e, ok := err.(*elastic.Error); ok {
+			status = e.Status
+		}
+		for _, item := range batch {
+			failure := BulkFailure{
+				Index:  item.index,
+				Type:   item.typename,
+				ID:     item.id,
+				Status: status,
+				Reason: err.Error(),
+			}
+			if status == http.StatusTooManyRequests {
+				rejected = append(rejected, failure)
+			} else {
+				failures = append(failures, failure)
+			}
+		}
+		return rejected, failures, 0
+	}
+	indexed := 0
+	for i, results := range res.Items {
+		for _, result := range results {
+			if result.Status >= 200 && result.Status <= 299 {
+				indexed++
+				continue
+			}
+			failure := BulkFailure{
+				Index:  result.Index,
+				Type:   result.Type,
+				ID:     result.Id,
+				Status: result.Status,
+			}
+			if i < len(batch) {
+				// Items are in the order of the requests
+				failure.Index = batch[i].index
+			}
+			if result.Error != nil {
+				failure.Reason = strings.TrimSpace(result.Error.Type + " " +
+					result.Error.Reason)
+			}
+			if result.Status == http.StatusTooManyRequests {
+				rejected = append(rejected, failure)
+			} else {
+				failures = append(failures, failure)
+			}
+		}
+	}
+	return rejected, failures, indexed
+}
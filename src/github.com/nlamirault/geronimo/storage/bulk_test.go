@@ -0,0 +1,260 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/olivere/elastic.v3"
+)
+
+// newBulkServer creates a fake Elasticsearch bulk API. The status of the
+// documents are given by status, called with the document ID and its number
+// of attempts.
+func newBulkServer(t *testing.T, status func(id string, attempt int) int) (*httptest.Server, *elastic.Client) {
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/_bulk") {
+			fmt.Fprint(w, `{}`)
+			return
+		}
+		var items []string
+		scanner := bufio.NewScanner(r.Body)
+		for line := 0; scanner.Scan(); line++ {
+			if line%2 == 1 {
+				continue
+			}
+			var action map[string]map[string]string
+			if err := json.Unmarshal(scanner.Bytes(), &action); err != nil {
+				t.Errorf("Invalid bulk action: %s", scanner.Text())
+				return
+			}
+			meta := action["index"]
+			mu.Lock()
+			attempts[meta["_id"]]++
+			code := status(meta["_id"], attempts[meta["_id"]])
+			mu.Unlock()
+			item := fmt.Sprintf(`{"index":{"_index":%q,"_type":%q,"_id":%q,"status":%d`,
+				meta["_index"], meta["_type"], meta["_id"], code)
+			if code >= 300 {
+				item += `,"error":{"type":"error","reason":"rejected"}`
+			}
+			items = append(items, item+"}}")
+		}
+		fmt.Fprintf(w, `{"took":1,"errors":true,"items":[%s]}`,
+			strings.Join(items, ","))
+	}))
+	client, err := elastic.NewClient(
+		elastic.SetURL(server.URL),
+		elastic.SetSniff(false),
+		elastic.SetHealthcheck(false))
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	return server, client
+}
+
+func TestBulkProcessor(t *testing.T) {
+	server, client := newBulkServer(t, func(id string, attempt int) int {
+		switch {
+		case id == "rejected" && attempt == 1:
+			return http.StatusTooManyRequests
+		case id == "invalid":
+			return http.StatusBadRequest
+		}
+		return http.StatusCreated
+	})
+	defer server.Close()
+
+	var sleeps []time.Duration
+	p := NewBulkProcessor(client)
+	p.Actions = 2
+	p.FlushInterval = 0
+	p.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+	p.Start()
+	for _, id := range []string{"1", "rejected", "invalid", "2", "3"} {
+		if err := p.Add("nlamirault_geronimo", "issue", id, map[string]string{"id": id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.Add("nlamirault", "user", "invalid", map[string]string{})
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Add("nlamirault", "user", "1", nil); err != ErrBulkProcessorClosed {
+		t.Fatalf("Invalid closed processor: %v", err)
+	}
+
+	stats := p.Stats()
+	if stats.Indexed != 4 || stats.Retried != 1 || stats.Failed != 2 {
+		t.Fatalf("Invalid stats: %#v", stats)
+	}
+	if len(sleeps) != 1 || sleeps[0] != DefaultBulkBackoff {
+		t.Fatalf("Invalid retries: %v", sleeps)
+	}
+	failures := p.Failures("", "nlamirault_geronimo")
+	if len(failures) != 1 || failures[0].ID != "invalid" ||
+		failures[0].Status != http.StatusBadRequest {
+		t.Fatalf("Invalid failures: %#v", failures)
+	}
+	failures = p.Failures("")
+	if len(failures) != 1 || failures[0].Index != "nlamirault" {
+		t.Fatalf("Invalid remaining failures: %#v", failures)
+	}
+}
+
+func TestBulkProcessorFailuresPrefix(t *testing.T) {
+	server, client := newBulkServer(t, func(id string, attempt int) int {
+		return http.StatusBadRequest
+	})
+	defer server.Close()
+
+	p := NewBulkProcessor(client)
+	p.Start()
+	defer p.Close()
+	for _, id := range []string{"nlamirault/geronimo/1", "nlamirault/geronimo-ui/1"} {
+		p.Add("geronimo_issue", "issue", id, map[string]string{})
+	}
+	p.Flush()
+	failures := p.Failures("nlamirault/geronimo/", "geronimo_issue")
+	if len(failures) != 1 || failures[0].ID != "nlamirault/geronimo/1" {
+		t.Fatalf("Invalid repository failures: %#v", failures)
+	}
+	// The failures of the other repository are kept for its writer
+	failures = p.Failures("")
+	if len(failures) != 1 || failures[0].ID != "nlamirault/geronimo-ui/1" {
+		t.Fatalf("Invalid remaining failures: %#v", failures)
+	}
+}
+
+func TestBulkProcessorFlushIndices(t *testing.T) {
+	server, client := newBulkServer(t, func(id string, attempt int) int {
+		return http.StatusCreated
+	})
+	defer server.Close()
+
+	p := NewBulkProcessor(client)
+	p.FlushInterval = 0
+	p.Start()
+	defer p.Close()
+	p.Add("nlamirault_geronimo", "issue", "1", map[string]string{})
+	p.Add("nlamirault", "user", "nlamirault", map[string]string{})
+	p.Add("nlamirault_geronimo", "issue", "2", map[string]string{})
+	p.FlushIndices("nlamirault_geronimo")
+	if stats := p.Stats(); stats.Requests != 1 || stats.Indexed != 2 {
+		t.Fatalf("Invalid flush of an index: %#v", stats)
+	}
+	// Nothing is sent for the indices without pending documents
+	p.FlushIndices("nlamirault_geronimo", "nlamirault_geronimo-ui")
+	if stats := p.Stats(); stats.Requests != 1 {
+		t.Fatalf("Invalid flush without pending documents: %#v", stats)
+	}
+	p.FlushIndices("nlamirault")
+	if stats := p.Stats(); stats.Requests != 2 || stats.Indexed != 3 {
+		t.Fatalf("Invalid flush of the other index: %#v", stats)
+	}
+}
+
+func TestBulkProcessorMaxRetries(t *testing.T) {
+	server, client := newBulkServer(t, func(id string, attempt int) int {
+		return http.StatusTooManyRequests
+	})
+	defer server.Close()
+
+	p := NewBulkProcessor(client)
+	p.MaxRetries = 2
+	p.sleep = func(time.Duration) {}
+	p.Start()
+	defer p.Close()
+	p.Add("nlamirault_geronimo", "issue", "1", map[string]string{})
+	p.Flush()
+	failures := p.Failures("")
+	if len(failures) != 1 || failures[0].Status != http.StatusTooManyRequests {
+		t.Fatalf("Invalid failures: %#v", failures)
+	}
+	if stats := p.Stats(); stats.Requests != 3 {
+		t.Fatalf("Invalid stats: %#v", stats)
+	}
+}
+
+func TestBulkProcessorFlushInterval(t *testing.T) {
+	server, client := newBulkServer(t, func(id string, attempt int) int {
+		return http.StatusCreated
+	})
+	defer server.Close()
+
+	p := NewBulkProcessor(client)
+	p.FlushInterval = 10 * time.Millisecond
+	p.Start()
+	defer p.Close()
+	p.Add("nlamirault_geronimo", "issue", "1", map[string]string{})
+	for i := 0; i < 100 && p.Stats().Indexed == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stats := p.Stats(); stats.Indexed != 1 {
+		t.Fatalf("Invalid periodic flush: %#v", stats)
+	}
+}
+
+func TestBulkProcessorConcurrentClose(t *testing.T) {
+	server, client := newBulkServer(t, func(id string, attempt int) int {
+		return http.StatusCreated
+	})
+	defer server.Close()
+	p := NewBulkProcessor(client)
+	p.Actions = 1
+	p.FlushInterval = time.Millisecond
+	p.Workers = 1
+	p.Start()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; ; j++ {
+				err := p.Add("geronimo", "issue", fmt.Sprintf("%d-%d", i, j), map[string]int{"number": j})
+				if err == ErrBulkProcessorClosed {
+					return
+				}
+				if j%10 == 0 {
+					p.Flush()
+				}
+			}
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	stats := p.Stats()
+	if stats.Failed != 0 {
+		t.Fatalf("Invalid stats: %#v", stats)
+	}
+	if err := p.Add("geronimo", "issue", "closed", nil); err != ErrBulkProcessorClosed {
+		t.Fatalf("Document added to a closed processor: %v", err)
+	}
+}
@@ -0,0 +1,495 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"gopkg.in/olivere/elastic.v3"
+
+	"github.com/nlamirault/geronimo/config"
+)
+
+// templateName is the name of the index template of the mappings, after the
+// prefix of the layout.
+const templateName = "geronimo"
+
+func init() {
+	Register("elasticsearch", func(conf *config.Configuration) (Backend, error) {
+		layout, err := NewLayout(conf.Storage)
+		if err != nil {
+			return nil, err
+		}
+		return NewElasticsearch(conf.ElasticSearch, layout)
+	})
+}
+
+// Elasticsearch stores the documents into Elasticsearch. Namespaces are
+// aliases of versioned indices, named <alias>@v<generation>, so an index can
+// be rebuilt and replaced without downtime. Documents are indexed with bulk
+// requests.
+type Elasticsearch struct {
+	uri    string
+	client *elastic.Client
+	bulk   *BulkProcessor
+	layout *Layout
+
+	mu      sync.Mutex
+	rebuild bool
+	// targets are the indices of the aliases being rebuilt
+	targets map[string]string
+	// namespaces are the aliases known to exist
+	namespaces map[string]bool
+	// unrefreshed are the indices written since their last refresh
+	unrefreshed map[string]bool
+}
+
+// NewElasticsearch creates a new Elasticsearch backend. The layout selects
+// the index template and the indices whose mappings are checked.
+func NewElasticsearch(conf config.ElasticsearchConfig, layout *Layout) (*Elasticsearch, error) {
+	client, err := elastic.NewClient(elastic.SetURL(conf.Host))
+	if err != nil {
+		return nil, err
+	}
+	es := newElasticsearch(conf, client)
+	es.layout = layout
+	if err := es.InstallTemplate(); err != nil {
+		es.Close()
+		return nil, fmt.Errorf("can't install index template: %s", err.Error())
+	}
+	outdated, err := es.OutdatedIndices()
+	if err != nil {
+		es.Close()
+		return nil, fmt.Errorf("can't check mappings: %s", err.Error())
+	}
+	for index, version := range outdated {
+		log.Printf("[WARN] Index %s uses mappings version %d, reindex it to use version %d",
+			index, version, MappingsVersion)
+	}
+	return es, nil
+}
+
+func newElasticsearch(conf config.ElasticsearchConfig, client *elastic.Client) *Elasticsearch {
+	bulk := NewBulkProcessor(client)
+	if conf.BulkActions > 0 {
+		bulk.Actions = conf.BulkActions
+	}
+	if conf.BulkFlushInterval > 0 {
+		bulk.FlushInterval = time.Duration(conf.BulkFlushInterval) * time.Second
+	}
+	if conf.BulkWorkers > 0 {
+		bulk.Workers = conf.BulkWorkers
+	}
+	if conf.BulkMaxRetries > 0 {
+		bulk.MaxRetries = conf.BulkMaxRetries
+	}
+	bulk.Start()
+	return &Elasticsearch{
+		uri:         conf.Host,
+		client:      client,
+		bulk:        bulk,
+		layout:      &Layout{Strategy: DefaultLayout},
+		targets:     map[string]string{},
+		namespaces:  map[string]bool{},
+		unrefreshed: map[string]bool{},
+	}
+}
+
+// Close indexes the pending documents.
+func (es *Elasticsearch) Close() error {
+	err := es.bulk.Close()
+	es.bulk.LogStats()
+	return err
+}
+
+// InstallTemplate installs the index template with the mappings of the
+// documents, unless the same or a more recent version is already installed.
+// The template applies to the indices created afterwards.
+func (es *Elasticsearch) InstallTemplate() error {
+	name := es.layout.Prefix + templateName
+	templates, err := es.client.IndexGetTemplate(name).Do()
+	if err != nil && !elastic.IsNotFound(err) {
+		return err
+	}
+	if template, ok := templates[name]; ok {
+		version := templateVersion(template.Mappings)
+		if version >= MappingsVersion {
+			log.Printf("[DEBUG] Index template %s version %d", name, version)
+			return nil
+		}
+		log.Printf("[INFO] Upgrade index template %s from version %d to %d",
+			name, version, MappingsVersion)
+	} else {
+		log.Printf("[INFO] Install index template %s version %d",
+			name, MappingsVersion)
+	}
+	_, err = es.client.IndexPutTemplate(name).
+		BodyJson(map[string]interface{}{
+			"template": es.layout.TemplatePattern(),
+			"order":    0,
+			"mappings": Mappings(),
+		}).
+		Do()
+	return err
+}
+
+// templateVersion returns the oldest version of the mappings of a template.
+func templateVersion(mappings map[string]interface{}) int {
+	version := 0
+	for typename, mapping := range mappings {
+		if _, ok := documentFields[typename]; !ok {
+			continue
+		}
+		v := mappingsVersion(mapping)
+		if version == 0 || v < version {
+			version = v
+		}
+	}
+	return version
+}
+
+// OutdatedIndices returns the indices of the layout with documents mapped
+// with a previous version of the mappings, and this version. Their mappings
+// can't be changed: they must be reindexed. Only the generation indices
+// created by Geronimo, whose mappings carry a version, are checked, so the
+// other indices of the cluster are left alone.
+func (es *Elasticsearch) OutdatedIndices() (map[string]int, error) {
+	res, err := es.client.GetMapping().Index(es.layout.TemplatePattern()).Do()
+	if err != nil {
+		return nil, err
+	}
+	outdated := map[string]int{}
+	for index, v := range res {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		mappings, ok := m["mappings"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		oldest, versioned := MappingsVersion, false
+		for typename, mapping := range mappings {
+			if _, ok := documentFields[typename]; !ok {
+				continue
+			}
+			version := mappingsVersion(mapping)
+			if version > 0 {
+				versioned = true
+			}
+			if version < oldest {
+				oldest = version
+			}
+		}
+		if versioned && oldest < MappingsVersion {
+			outdated[index] = oldest
+		}
+	}
+	return outdated, nil
+}
+
+// Ping returns the version of Elasticsearch.
+func (es *Elasticsearch) Ping() (string, error) {
+	info, _, err := es.client.Ping(es.uri).Do()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Elasticsearch %s", info.Version.Number), nil
+}
+
+// CreateNamespace creates an alias, and the first index behind it, if it
+// doesn't exist. During a rebuild, it creates the next index of the alias.
+func (es *Elasticsearch) CreateNamespace(alias string) error {
+	es.mu.Lock()
+	if es.rebuild {
+		es.mu.Unlock()
+		_, err := es.target(alias)
+		return err
+	}
+	known := es.namespaces[alias]
+	es.mu.Unlock()
+	if known {
+		return nil
+	}
+	log.Printf("[DEBUG] Search index %s", alias)
+	exists, err := es.client.IndexExists(alias).Do()
+	if err != nil {
+		return err
+	}
+	if !exists {
+		index := GenerationIndex(alias, 1)
+		log.Printf("[INFO] Create index %s with alias %s", index, alias)
+		_, err = es.client.CreateIndex(index).
+			BodyJson(map[string]interface{}{
+				"aliases": map[string]interface{}{
+					alias: map[string]interface{}{},
+				},
+			}).
+			Do()
+		if err != nil {
+			return err
+		}
+	}
+	es.mu.Lock()
+	es.namespaces[alias] = true
+	es.mu.Unlock()
+	return nil
+}
+
+// Upsert queues a document to index.
+func (es *Elasticsearch) Upsert(index string, typename string, id string,
+	body interface{}) error {
+	target, err := es.target(index)
+	if err != nil {
+		return err
+	}
+	es.written(target)
+	return es.bulk.Add(target, typename, id, body)
+}
+
+// BulkUpsert queues several documents to index.
+func (es *Elasticsearch) BulkUpsert(alias string, documents []Document) error {
+	index, err := es.target(alias)
+	if err != nil {
+		return err
+	}
+	es.written(index)
+	for _, doc := range documents {
+		if err := es.bulk.Add(index, doc.Type, doc.ID, doc.Body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush indexes the pending documents of the indices, or of all the indices
+// if none is given.
+func (es *Elasticsearch) Flush(prefix string, aliases ...string) error {
+	var indices []string
+	for _, alias := range aliases {
+		index, err := es.target(alias)
+		if err != nil {
+			return err
+		}
+		indices = append(indices, index)
+	}
+	if len(indices) > 0 {
+		es.bulk.FlushIndices(indices...)
+	} else {
+		es.bulk.Flush()
+	}
+	if failures := es.bulk.Failures(prefix, indices...); len(failures) > 0 {
+		return &BulkError{Failures: failures}
+	}
+	return nil
+}
+
+// Count returns the number of documents of a type into an index, filtered
+// with term queries.
+func (es *Elasticsearch) Count(alias string, typename string,
+	terms map[string]interface{}) (int64, error) {
+	index, err := es.target(alias)
+	if err != nil {
+		return 0, err
+	}
+	es.bulk.FlushIndices(index)
+	exists, err := es.client.IndexExists(index).Do()
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, nil
+	}
+	count := es.client.Count(index).Type(typename)
+	if len(terms) > 0 {
+		count.Query(termsQuery(terms))
+	}
+	return count.Do()
+}
+
+// Get retrieves a document from an index and decodes it into v.
+func (es *Elasticsearch) Get(alias string, typename string, id string,
+	v interface{}) (bool, error) {
+	index, err := es.target(alias)
+	if err != nil {
+		return false, err
+	}
+	es.bulk.FlushIndices(index)
+	res, err := es.client.Get().
+		Index(index).
+		Type(typename).
+		Id(id).
+		Do()
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if !res.Found || res.Source == nil {
+		return false, nil
+	}
+	return true, json.Unmarshal(*res.Source, v)
+}
+
+// Query retrieves the documents of a type from an index, filtered with term
+// queries.
+func (es *Elasticsearch) Query(alias string, typename string,
+	terms map[string]interface{}) ([]*json.RawMessage, error) {
+	documents, err := es.QueryDocuments(alias, typename, terms)
+	if err != nil {
+		return nil, err
+	}
+	return bodies(documents), nil
+}
+
+// QueryDocuments retrieves the documents of a type from an index, filtered
+// with term queries, with their IDs.
+func (es *Elasticsearch) QueryDocuments(alias string, typename string,
+	terms map[string]interface{}) ([]Document, error) {
+	var documents []Document
+	err := es.scroll(alias, typename, terms, func(hit *elastic.SearchHit) {
+		documents = append(documents, Document{Type: typename, ID: hit.Id, Body: hit.Source})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+// scroll calls fn with the documents of a type from an index, filtered with
+// term queries, once the pending documents of the index are indexed. The
+// index is refreshed only if it was written since its last refresh.
+func (es *Elasticsearch) scroll(alias string, typename string,
+	terms map[string]interface{}, fn func(hit *elastic.SearchHit)) error {
+	index, err := es.target(alias)
+	if err != nil {
+		return err
+	}
+	// The index is marked refreshed before its documents are sent, so the
+	// documents added meanwhile are refreshed by the next scroll
+	unrefreshed := es.refreshed(index)
+	es.bulk.FlushIndices(index)
+	exists, err := es.client.IndexExists(index).Do()
+	if err != nil || !exists {
+		return err
+	}
+	if unrefreshed {
+		if _, err := es.client.Refresh(index).Do(); err != nil {
+			es.written(index)
+			return err
+		}
+	}
+	scroll := es.client.Scroll(index).Type(typename).Size(100)
+	if len(terms) > 0 {
+		scroll.Query(termsQuery(terms))
+	}
+	res, err := scroll.Do()
+	for err == nil {
+		for _, hit := range res.Hits.Hits {
+			fn(hit)
+		}
+		res, err = scroll.ScrollId(res.ScrollId).Do()
+	}
+	if err != elastic.EOS {
+		return err
+	}
+	return nil
+}
+
+// termsQuery returns the query of the documents whose fields are equal to the
+// terms.
+func termsQuery(terms map[string]interface{}) elastic.Query {
+	query := elastic.NewBoolQuery()
+	for field, value := range terms {
+		query.Filter(elastic.NewTermQuery(field, value))
+	}
+	return query
+}
+
+// DeleteQuery removes the documents of a type from an index, filtered with
+// term queries.
+func (es *Elasticsearch) DeleteQuery(alias string, typename string,
+	terms map[string]interface{}) (int64, error) {
+	var ids []string
+	err := es.scroll(alias, typename, terms, func(hit *elastic.SearchHit) {
+		ids = append(ids, hit.Id)
+	})
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	index, err := es.target(alias)
+	if err != nil {
+		return 0, err
+	}
+	es.written(index)
+	bulk := es.client.Bulk()
+	for _, id := range ids {
+		bulk.Add(elastic.NewBulkDeleteRequest().
+			Index(index).
+			Type(typename).
+			Id(id))
+	}
+	res, err := bulk.Do()
+	if err != nil {
+		return 0, err
+	}
+	if failed := res.Failed(); len(failed) > 0 {
+		return int64(len(ids) - len(failed)), fmt.Errorf(
+			"can't delete %d documents from %s", len(failed), index)
+	}
+	return int64(len(ids)), nil
+}
+
+// Delete removes a document from an index.
+func (es *Elasticsearch) Delete(alias string, typename string, id string) error {
+	index, err := es.target(alias)
+	if err != nil {
+		return err
+	}
+	// A pending upsert of the document would be indexed after its deletion
+	es.bulk.FlushIndices(index)
+	es.written(index)
+	_, err = es.client.Delete().
+		Index(index).
+		Type(typename).
+		Id(id).
+		Do()
+	if err != nil && !elastic.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// written marks an index as written since its last refresh.
+func (es *Elasticsearch) written(index string) {
+	es.mu.Lock()
+	es.unrefreshed[index] = true
+	es.mu.Unlock()
+}
+
+// refreshed marks an index as refreshed, and returns true if it was written
+// since its last refresh.
+func (es *Elasticsearch) refreshed(index string) bool {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	unrefreshed := es.unrefreshed[index]
+	delete(es.unrefreshed, index)
+	return unrefreshed
+}
@@ -0,0 +1,176 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"gopkg.in/olivere/elastic.v3"
+
+	"github.com/nlamirault/geronimo/config"
+)
+
+func newTestElasticsearch(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *Elasticsearch) {
+	server := httptest.NewServer(handler)
+	client, err := elastic.NewClient(
+		elastic.SetURL(server.URL),
+		elastic.SetSniff(false),
+		elastic.SetHealthcheck(false))
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	return server, newElasticsearch(config.ElasticsearchConfig{Host: server.URL}, client)
+}
+
+func TestInstallTemplate(t *testing.T) {
+	var template map[string]interface{}
+	server, es := newTestElasticsearch(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_template/acme_geronimo" {
+			t.Errorf("Invalid request: %s %s", r.Method, r.URL.Path)
+			return
+		}
+		switch r.Method {
+		case "GET":
+			if template == nil {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{}`)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"acme_geronimo": template,
+			})
+		case "PUT":
+			json.NewDecoder(r.Body).Decode(&template)
+			fmt.Fprint(w, `{"acknowledged":true}`)
+		}
+	})
+	defer server.Close()
+	defer es.Close()
+	es.layout = &Layout{Strategy: LayoutType, Prefix: "acme_"}
+
+	if err := es.InstallTemplate(); err != nil {
+		t.Fatal(err)
+	}
+	if template == nil || template["template"] != "acme_geronimo_*@v*" {
+		t.Fatalf("Invalid template: %#v", template)
+	}
+	mappings := template["mappings"].(map[string]interface{})
+	if version := templateVersion(mappings); version != MappingsVersion {
+		t.Fatalf("Invalid template version: %d", version)
+	}
+	// The template is up to date
+	template["template"] = "foo"
+	if err := es.InstallTemplate(); err != nil {
+		t.Fatal(err)
+	}
+	if template["template"] != "foo" {
+		t.Fatalf("Invalid template update: %#v", template["template"])
+	}
+}
+
+func TestOutdatedIndices(t *testing.T) {
+	server, es := newTestElasticsearch(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/*@v*/_mapping") {
+			t.Errorf("Invalid mappings request: %s", r.URL.Path)
+		}
+		fmt.Fprintf(w, `{
+"nlamirault_geronimo@v1": {"mappings": {
+	"issue": {"_meta": {"version": %d}, "properties": {}},
+	"commit": {"properties": {}}
+}},
+"nlamirault_geronimo-ui@v2": {"mappings": {
+	"issue": {"_meta": {"version": %d}, "properties": {}}
+}},
+"nlamirault@v1": {"mappings": {
+	"repository": {"_meta": {"version": %d}, "properties": {}}
+}},
+"other@v1": {"mappings": {
+	"issue": {"properties": {}}
+}}}`, MappingsVersion, MappingsVersion-1, MappingsVersion)
+	})
+	defer server.Close()
+	defer es.Close()
+
+	outdated, err := es.OutdatedIndices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outdated) != 2 || outdated["nlamirault_geronimo@v1"] != 0 ||
+		outdated["nlamirault_geronimo-ui@v2"] != MappingsVersion-1 {
+		t.Fatalf("Invalid outdated indices: %v", outdated)
+	}
+}
+
+func TestScrollRefreshesWrittenIndex(t *testing.T) {
+	var mu sync.Mutex
+	var sent []string
+	server, es := newTestElasticsearch(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sent = append(sent, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/_bulk"):
+			fmt.Fprint(w, `{"took":1,"errors":false,"items":[{"index":{"_index":"nlamirault_geronimo","_type":"issue","_id":"1","status":201}}]}`)
+		case strings.HasSuffix(r.URL.Path, "/_search"):
+			fmt.Fprint(w, `{"_scroll_id":"1","hits":{"total":0,"hits":[]}}`)
+		default:
+			fmt.Fprint(w, `{}`)
+		}
+	})
+	defer server.Close()
+	defer es.Close()
+
+	query := func() []string {
+		mu.Lock()
+		sent = nil
+		mu.Unlock()
+		if _, err := es.Query("nlamirault_geronimo", "issue", nil); err != nil {
+			t.Fatal(err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return sent
+	}
+	contains := func(requests []string, suffix string) bool {
+		for _, request := range requests {
+			if strings.HasSuffix(request, suffix) {
+				return true
+			}
+		}
+		return false
+	}
+	if requests := query(); contains(requests, "/_refresh") {
+		t.Fatalf("Invalid refresh of an index not written: %v", requests)
+	}
+	es.Upsert("nlamirault", "user", "nlamirault", map[string]string{})
+	if requests := query(); contains(requests, "/_bulk") || contains(requests, "/_refresh") {
+		t.Fatalf("Invalid flush of another index: %v", requests)
+	}
+	es.Upsert("nlamirault_geronimo", "issue", "1", map[string]string{})
+	requests := query()
+	if !contains(requests, "/_bulk") || !contains(requests, "/nlamirault_geronimo/_refresh") {
+		t.Fatalf("Invalid flush of the written index: %v", requests)
+	}
+	if requests := query(); contains(requests, "/_refresh") {
+		t.Fatalf("Invalid refresh of a refreshed index: %v", requests)
+	}
+}
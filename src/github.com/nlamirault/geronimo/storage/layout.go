@@ -0,0 +1,185 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nlamirault/geronimo/config"
+)
+
+const (
+	// LayoutType stores the documents of each type into one index named
+	// <prefix>geronimo_<type>. The documents of a repository are identified
+	// by their owner and repository fields.
+	LayoutType = "type"
+
+	// LayoutRepository stores the documents of an owner, including its
+	// repositories, into an index named <prefix><owner>, and the other
+	// documents of a repository, including its checkpoints, into an index
+	// named <prefix><owner>_<repository>.
+	LayoutRepository = "repository"
+
+	// DefaultLayout is the layout used when none is configured, the one of
+	// the indices created before the layouts.
+	DefaultLayout = LayoutRepository
+
+	// layoutTypeIndex is the name of the indices of LayoutType, before the
+	// type.
+	layoutTypeIndex = "geronimo_"
+)
+
+var (
+	// ownerTypes are the document types of a user or an organization. The
+	// repositories are stored with their owner so they can be listed, to be
+	// reconciled with GitHub.
+	ownerTypes = []string{"user", "organization", "user_contributor", "repository"}
+
+	// repositoryTypes are the document types of a repository.
+	repositoryTypes = []string{
+		"repository_snapshot",
+		"issue",
+		"pull_request",
+		"commit",
+		"commit_author",
+		"contributor",
+		"release",
+		"asset_snapshot",
+		"tag",
+		"star",
+		"checkpoint",
+	}
+)
+
+// Layout is the naming strategy of the namespaces and IDs of the documents.
+// All the writers must use it so the documents of a type are always stored
+// at the same place.
+type Layout struct {
+	// Strategy is LayoutType or LayoutRepository.
+	Strategy string
+
+	// Prefix is prepended to the namespaces, to share a cluster between
+	// several Geronimo instances.
+	Prefix string
+}
+
+// NewLayout creates the layout of the storage configuration.
+func NewLayout(conf config.StorageConfig) (*Layout, error) {
+	layout := &Layout{
+		Strategy: conf.Layout,
+		Prefix:   strings.ToLower(conf.IndexPrefix),
+	}
+	switch layout.Strategy {
+	case "":
+		layout.Strategy = DefaultLayout
+	case LayoutType, LayoutRepository:
+	default:
+		return nil, fmt.Errorf("unknown layout %s", conf.Layout)
+	}
+	return layout, nil
+}
+
+// Index returns the namespace of a document type. The repository is empty
+// for the documents of an owner.
+func (l *Layout) Index(typename string, owner string, repository string) string {
+	if l.Strategy == LayoutType {
+		return l.Prefix + layoutTypeIndex + typename
+	}
+	if repository == "" {
+		return strings.ToLower(l.Prefix + owner)
+	}
+	return strings.ToLower(fmt.Sprintf("%s%s_%s", l.Prefix, owner, repository))
+}
+
+// Indices returns the namespaces of the document types of a repository.
+func (l *Layout) Indices(owner string, repository string) []string {
+	seen := map[string]bool{}
+	var indices []string
+	for _, typename := range repositoryTypes {
+		index := l.Index(typename, owner, repository)
+		if !seen[index] {
+			seen[index] = true
+			indices = append(indices, index)
+		}
+	}
+	sort.Strings(indices)
+	return indices
+}
+
+// ID returns the ID of a document of an owner or of a repository, unique
+// within its namespace.
+func (l *Layout) ID(owner string, repository string, id string) string {
+	if l.Strategy != LayoutType {
+		return id
+	}
+	parts := []string{strings.ToLower(owner)}
+	if repository != "" {
+		parts = append(parts, strings.ToLower(repository))
+	}
+	return strings.Join(append(parts, id), "/")
+}
+
+// LocalID returns the ID of a document within its owner or repository, from
+// the ID returned by ID.
+func (l *Layout) LocalID(owner string, repository string, id string) string {
+	if l.Strategy != LayoutType {
+		return id
+	}
+	return strings.TrimPrefix(id, l.ID(owner, repository, ""))
+}
+
+// Terms returns the terms selecting the documents of a type of a repository
+// in its namespace, or nil if the namespace only holds this repository.
+func (l *Layout) Terms(typename string, owner string, repository string) map[string]interface{} {
+	if l.Strategy != LayoutType {
+		return nil
+	}
+	return RepositoryFields(typename, owner, repository)
+}
+
+// RepositoryFields returns the fields of a document of a type identifying its
+// repository.
+func RepositoryFields(typename string, owner string, repository string) map[string]interface{} {
+	field := "repository"
+	if typename == "repository_snapshot" {
+		field = "name"
+	}
+	return map[string]interface{}{
+		"owner": strings.ToLower(owner),
+		field:   repository,
+	}
+}
+
+// RepositoryTypes returns the document types of a repository, stored into
+// the namespaces returned by Indices.
+func RepositoryTypes() []string {
+	return append([]string{}, repositoryTypes...)
+}
+
+// Pattern matches the namespaces of the layout.
+func (l *Layout) Pattern() string {
+	if l.Strategy == LayoutType {
+		return l.Prefix + layoutTypeIndex + "*"
+	}
+	return l.Prefix + "*"
+}
+
+// TemplatePattern matches the indices created by Geronimo for the namespaces
+// of the layout, named after their alias and generation.
+func (l *Layout) TemplatePattern() string {
+	return l.Pattern() + generationSeparator + "*"
+}
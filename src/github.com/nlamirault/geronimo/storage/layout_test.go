@@ -0,0 +1,112 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nlamirault/geronimo/config"
+)
+
+func TestNewLayout(t *testing.T) {
+	layout, err := NewLayout(config.StorageConfig{IndexPrefix: "Acme_"})
+	if err != nil || layout.Strategy != LayoutRepository || layout.Prefix != "acme_" {
+		t.Fatalf("Invalid default layout: %#v %v", layout, err)
+	}
+	if _, err := NewLayout(config.StorageConfig{Layout: "foo"}); err == nil {
+		t.Fatalf("Invalid unknown layout")
+	}
+}
+
+func TestLayoutCoversTypes(t *testing.T) {
+	types := append(append([]string{}, ownerTypes...), repositoryTypes...)
+	if len(types) != len(documentFields) {
+		t.Fatalf("Invalid layout types: %v", types)
+	}
+	for _, typename := range types {
+		if _, ok := documentFields[typename]; !ok {
+			t.Fatalf("No mappings for %s", typename)
+		}
+	}
+}
+
+func TestTypeLayout(t *testing.T) {
+	layout := &Layout{Strategy: LayoutType, Prefix: "acme_"}
+	if index := layout.Index("issue", "nlamirault", "geronimo"); index != "acme_geronimo_issue" {
+		t.Fatalf("Invalid repository index: %s", index)
+	}
+	if index := layout.Index("user", "nlamirault", ""); index != "acme_geronimo_user" {
+		t.Fatalf("Invalid owner index: %s", index)
+	}
+	if id := layout.ID("nlamirault", "Geronimo", "42"); id != "nlamirault/geronimo/42" {
+		t.Fatalf("Invalid repository ID: %s", id)
+	}
+	if id := layout.ID("nlamirault", "", "42"); id != "nlamirault/42" {
+		t.Fatalf("Invalid owner ID: %s", id)
+	}
+	terms := layout.Terms("issue", "NLamirault", "geronimo")
+	if terms["owner"] != "nlamirault" || terms["repository"] != "geronimo" {
+		t.Fatalf("Invalid terms: %v", terms)
+	}
+	terms = layout.Terms("repository_snapshot", "nlamirault", "geronimo")
+	if terms["name"] != "geronimo" {
+		t.Fatalf("Invalid snapshot terms: %v", terms)
+	}
+	if indices := layout.Indices("nlamirault", "geronimo"); len(indices) != len(repositoryTypes) {
+		t.Fatalf("Invalid indices: %v", indices)
+	}
+	if pattern := layout.Pattern(); pattern != "acme_geronimo_*" {
+		t.Fatalf("Invalid pattern: %s", pattern)
+	}
+	if id := layout.LocalID("NLamirault", "geronimo", "nlamirault/geronimo/42"); id != "42" {
+		t.Fatalf("Invalid local ID: %s", id)
+	}
+	if pattern := layout.TemplatePattern(); pattern != "acme_geronimo_*@v*" {
+		t.Fatalf("Invalid template pattern: %s", pattern)
+	}
+}
+
+func TestRepositoryLayout(t *testing.T) {
+	layout := &Layout{Strategy: LayoutRepository}
+	if index := layout.Index("issue", "nlamirault", "Geronimo"); index != "nlamirault_geronimo" {
+		t.Fatalf("Invalid repository index: %s", index)
+	}
+	if index := layout.Index("checkpoint", "nlamirault", "geronimo"); index != "nlamirault_geronimo" {
+		t.Fatalf("Invalid checkpoint index: %s", index)
+	}
+	if index := layout.Index("user", "NLamirault", ""); index != "nlamirault" {
+		t.Fatalf("Invalid owner index: %s", index)
+	}
+	if id := layout.ID("nlamirault", "geronimo", "42"); id != "42" {
+		t.Fatalf("Invalid ID: %s", id)
+	}
+	if id := layout.LocalID("nlamirault", "geronimo", "42"); id != "42" {
+		t.Fatalf("Invalid local ID: %s", id)
+	}
+	if terms := layout.Terms("issue", "nlamirault", "geronimo"); terms != nil {
+		t.Fatalf("Invalid terms: %v", terms)
+	}
+	indices := layout.Indices("nlamirault", "geronimo")
+	if !reflect.DeepEqual(indices, []string{"nlamirault_geronimo"}) {
+		t.Fatalf("Invalid indices: %v", indices)
+	}
+	if pattern := layout.Pattern(); pattern != "*" {
+		t.Fatalf("Invalid pattern: %s", pattern)
+	}
+	if pattern := layout.TemplatePattern(); pattern != "*@v*" {
+		t.Fatalf("Invalid template pattern: %s", pattern)
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+const (
+	// MappingsVersion is the version of the mappings of the documents. It
+	// must be incremented on each change of the mappings: the indices
+	// created with a previous version must be reindexed.
+	MappingsVersion = 3
+
+	// Field kinds of the mappings.
+	fieldKeyword = "keyword"
+	fieldText    = "text"
+	fieldDate    = "date"
+	fieldInteger = "integer"
+	fieldLong    = "long"
+	fieldBoolean = "boolean"
+)
+
+// documentFields are the kinds of the fields of each document type. Keywords
+// are indexed as is, to be used in filters and aggregations, while texts
+// are analyzed for full text search.
+var documentFields = map[string]map[string]string{
+	"user": {
+		"user":     fieldKeyword,
+		"name":     fieldKeyword,
+		"company":  fieldKeyword,
+		"email":    fieldKeyword,
+		"location": fieldKeyword,
+	},
+	"organization": {
+		"organization":      fieldKeyword,
+		"name":              fieldKeyword,
+		"company":           fieldKeyword,
+		"email":             fieldKeyword,
+		"location":          fieldKeyword,
+		"public_repo_count": fieldInteger,
+		"member_count":      fieldInteger,
+		"teams":             fieldKeyword,
+	},
+	"repository": {
+		"owner":            fieldKeyword,
+		"id":               fieldLong,
+		"name":             fieldKeyword,
+		"description":      fieldText,
+		"created":          fieldDate,
+		"language":         fieldKeyword,
+		"fork_count":       fieldInteger,
+		"star_count":       fieldInteger,
+		"subscriber_count": fieldInteger,
+		"watcher_count":    fieldInteger,
+		"open_issue_count": fieldInteger,
+		"deleted":          fieldDate,
+	},
+	"repository_snapshot": {
+		"owner":            fieldKeyword,
+		"id":               fieldLong,
+		"name":             fieldKeyword,
+		"fork_count":       fieldInteger,
+		"star_count":       fieldInteger,
+		"subscriber_count": fieldInteger,
+		"watcher_count":    fieldInteger,
+		"open_issue_count": fieldInteger,
+		"date":             fieldDate,
+	},
+	"issue": {
+		"owner":         fieldKeyword,
+		"repository":    fieldKeyword,
+		"number":        fieldInteger,
+		"title":         fieldText,
+		"state":         fieldKeyword,
+		"labels":        fieldKeyword,
+		"assignees":     fieldKeyword,
+		"author":        fieldKeyword,
+		"created":       fieldDate,
+		"closed":        fieldDate,
+		"comment_count": fieldInteger,
+	},
+	"pull_request": {
+		"owner":                fieldKeyword,
+		"repository":           fieldKeyword,
+		"number":               fieldInteger,
+		"title":                fieldText,
+		"state":                fieldKeyword,
+		"author":               fieldKeyword,
+		"base_branch":          fieldKeyword,
+		"head_branch":          fieldKeyword,
+		"draft":                fieldBoolean,
+		"additions":            fieldInteger,
+		"deletions":            fieldInteger,
+		"changed_files":        fieldInteger,
+		"commit_count":         fieldInteger,
+		"comment_count":        fieldInteger,
+		"review_comment_count": fieldInteger,
+		"review_count":         fieldInteger,
+		"merged":               fieldBoolean,
+		"merged_by":            fieldKeyword,
+		"created":              fieldDate,
+		"first_reviewed":       fieldDate,
+		"merged_at":            fieldDate,
+		"closed":               fieldDate,
+		"time_to_first_review": fieldLong,
+		"time_to_merge":        fieldLong,
+	},
+	"commit": {
+		"owner":           fieldKeyword,
+		"repository":      fieldKeyword,
+		"sha":             fieldKeyword,
+		"author":          fieldKeyword,
+		"author_name":     fieldKeyword,
+		"author_email":    fieldKeyword,
+		"authored":        fieldDate,
+		"committer":       fieldKeyword,
+		"committer_name":  fieldKeyword,
+		"committer_email": fieldKeyword,
+		"committed":       fieldDate,
+		"message":         fieldText,
+		"additions":       fieldInteger,
+		"deletions":       fieldInteger,
+		"files":           fieldKeyword,
+	},
+	"commit_author": {
+		"owner":        fieldKeyword,
+		"repository":   fieldKeyword,
+		"author":       fieldKeyword,
+		"commit_count": fieldInteger,
+		"additions":    fieldInteger,
+		"deletions":    fieldInteger,
+		"first_commit": fieldDate,
+		"last_commit":  fieldDate,
+	},
+	"contributor": {
+		"owner":              fieldKeyword,
+		"repository":         fieldKeyword,
+		"login":              fieldKeyword,
+		"contribution_count": fieldInteger,
+		"first_contribution": fieldDate,
+		"last_contribution":  fieldDate,
+	},
+	"user_contributor": {
+		"owner":              fieldKeyword,
+		"login":              fieldKeyword,
+		"contribution_count": fieldInteger,
+		"repositories":       fieldKeyword,
+		"first_contribution": fieldDate,
+		"last_contribution":  fieldDate,
+	},
+	"release": {
+		"owner":          fieldKeyword,
+		"repository":     fieldKeyword,
+		"id":             fieldLong,
+		"tag":            fieldKeyword,
+		"name":           fieldKeyword,
+		"draft":          fieldBoolean,
+		"prerelease":     fieldBoolean,
+		"author":         fieldKeyword,
+		"created":        fieldDate,
+		"published":      fieldDate,
+		"asset_count":    fieldInteger,
+		"download_count": fieldInteger,
+	},
+	"asset_snapshot": {
+		"owner":          fieldKeyword,
+		"repository":     fieldKeyword,
+		"release":        fieldKeyword,
+		"id":             fieldLong,
+		"name":           fieldKeyword,
+		"download_count": fieldInteger,
+		"date":           fieldDate,
+	},
+	"tag": {
+		"owner":      fieldKeyword,
+		"repository": fieldKeyword,
+		"name":       fieldKeyword,
+		"sha":        fieldKeyword,
+	},
+	"star": {
+		"owner":      fieldKeyword,
+		"repository": fieldKeyword,
+		"user":       fieldKeyword,
+		"starred_at": fieldDate,
+	},
+	"checkpoint": {
+		"owner":        fieldKeyword,
+		"repository":   fieldKeyword,
+		"type":         fieldKeyword,
+		"last_updated": fieldDate,
+		"last_run":     fieldDate,
+	},
+}
+
+// fieldMapping returns the Elasticsearch 2.x mapping of a field kind.
+func fieldMapping(kind string) map[string]interface{} {
+	switch kind {
+	case fieldKeyword:
+		return map[string]interface{}{"type": "string", "index": "not_analyzed"}
+	case fieldText:
+		return map[string]interface{}{
+			"type": "string",
+			"fields": map[string]interface{}{
+				"raw": map[string]interface{}{
+					"type":         "string",
+					"index":        "not_analyzed",
+					"ignore_above": 256,
+				},
+			},
+		}
+	case fieldDate:
+		return map[string]interface{}{
+			"type":   "date",
+			"format": "strict_date_optional_time||epoch_millis",
+		}
+	}
+	return map[string]interface{}{"type": kind}
+}
+
+// Mappings returns the Elasticsearch mappings of the document types. The
+// version of the mappings is stored in the _meta field of each type.
+func Mappings() map[string]interface{} {
+	mappings := map[string]interface{}{}
+	for typename, fields := range documentFields {
+		properties := map[string]interface{}{}
+		for field, kind := range fields {
+			properties[field] = fieldMapping(kind)
+		}
+		mappings[typename] = map[string]interface{}{
+			"_meta":      map[string]interface{}{"version": MappingsVersion},
+			"properties": properties,
+		}
+	}
+	return mappings
+}
+
+// mappingsVersion returns the version of the mappings of a type, from the
+// _meta field of its mapping. It returns 0 if the type has no version.
+func mappingsVersion(mapping interface{}) int {
+	m, ok := mapping.(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	meta, ok := m["_meta"].(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	switch version := meta["version"].(type) {
+	case float64:
+		return int(version)
+	case int:
+		return version
+	}
+	return 0
+}
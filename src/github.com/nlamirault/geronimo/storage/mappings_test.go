@@ -0,0 +1,79 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMappingsCoverModels(t *testing.T) {
+	models := map[string][]interface{}{
+		"user":                {User{}},
+		"organization":        {Organization{}},
+		"repository":          {Repository{}},
+		"repository_snapshot": {RepositorySnapshot{}},
+		"issue":               {Issue{}},
+		"pull_request":        {PullRequest{}},
+		"commit":              {Commit{}},
+		"commit_author":       {CommitAuthor{}},
+		"contributor":         {Contributor{}},
+		"user_contributor":    {UserContributor{}},
+		"release":             {Release{}},
+		"asset_snapshot":      {AssetSnapshot{}},
+		"tag":                 {Tag{}},
+		"star":                {Star{}},
+		"checkpoint":          {Checkpoint{}},
+	}
+	if len(models) != len(documentFields) {
+		t.Fatalf("Invalid mapped types: %d", len(documentFields))
+	}
+	for typename, values := range models {
+		fields, ok := documentFields[typename]
+		if !ok {
+			t.Fatalf("No mappings for %s", typename)
+		}
+		for _, value := range values {
+			model := reflect.TypeOf(value)
+			for i := 0; i < model.NumField(); i++ {
+				name := strings.Split(model.Field(i).Tag.Get("json"), ",")[0]
+				if _, ok := fields[name]; !ok {
+					t.Fatalf("No mapping for %s field %s", typename, name)
+				}
+			}
+		}
+	}
+}
+
+func TestMappings(t *testing.T) {
+	mappings := Mappings()
+	issue := mappings["issue"].(map[string]interface{})
+	if version := mappingsVersion(issue); version != MappingsVersion {
+		t.Fatalf("Invalid mappings version: %d", version)
+	}
+	properties := issue["properties"].(map[string]interface{})
+	created := properties["created"].(map[string]interface{})
+	if created["type"] != "date" {
+		t.Fatalf("Invalid date mapping: %#v", created)
+	}
+	state := properties["state"].(map[string]interface{})
+	if state["type"] != "string" || state["index"] != "not_analyzed" {
+		t.Fatalf("Invalid keyword mapping: %#v", state)
+	}
+	if mappingsVersion(map[string]interface{}{}) != 0 {
+		t.Fatalf("Invalid mappings without version")
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/nlamirault/geronimo/config"
+)
+
+func init() {
+	Register("memory", func(conf *config.Configuration) (Backend, error) {
+		return NewMemory(), nil
+	})
+}
+
+// Memory keeps the documents in memory. Nothing is persisted: it is meant
+// for tests and dry runs.
+type Memory struct {
+	mu         sync.RWMutex
+	namespaces map[string]map[string]map[string]json.RawMessage
+}
+
+// NewMemory creates an empty memory backend.
+func NewMemory() *Memory {
+	return &Memory{
+		namespaces: map[string]map[string]map[string]json.RawMessage{},
+	}
+}
+
+// Ping always succeeds.
+func (m *Memory) Ping() (string, error) {
+	return "Memory", nil
+}
+
+// CreateNamespace creates a namespace if it doesn't exist.
+func (m *Memory) CreateNamespace(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.namespaces[name]; !ok {
+		m.namespaces[name] = map[string]map[string]json.RawMessage{}
+	}
+	return nil
+}
+
+// Upsert stores the JSON encoding of a document.
+func (m *Memory) Upsert(namespace string, typename string, id string,
+	body interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	types, ok := m.namespaces[namespace]
+	if !ok {
+		types = map[string]map[string]json.RawMessage{}
+		m.namespaces[namespace] = types
+	}
+	documents, ok := types[typename]
+	if !ok {
+		documents = map[string]json.RawMessage{}
+		types[typename] = documents
+	}
+	documents[id] = b
+	return nil
+}
+
+// BulkUpsert stores several documents.
+func (m *Memory) BulkUpsert(namespace string, documents []Document) error {
+	for _, doc := range documents {
+		if err := m.Upsert(namespace, doc.Type, doc.ID, doc.Body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get decodes a document into v.
+func (m *Memory) Get(namespace string, typename string, id string,
+	v interface{}) (bool, error) {
+	m.mu.RLock()
+	b, ok := m.namespaces[namespace][typename][id]
+	m.mu.RUnlock()
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(b, v)
+}
+
+// Count returns the number of documents of a type whose top level fields are
+// equal to the terms.
+func (m *Memory) Count(namespace string, typename string,
+	terms map[string]interface{}) (int64, error) {
+	documents, err := m.Query(namespace, typename, terms)
+	return int64(len(documents)), err
+}
+
+// Query returns the documents of a type, sorted by ID, whose top level
+// fields are equal to the terms.
+func (m *Memory) Query(namespace string, typename string,
+	terms map[string]interface{}) ([]*json.RawMessage, error) {
+	documents, err := m.QueryDocuments(namespace, typename, terms)
+	if err != nil {
+		return nil, err
+	}
+	return bodies(documents), nil
+}
+
+// QueryDocuments returns the documents of a type, sorted by ID, whose top
+// level fields are equal to the terms, with their IDs.
+func (m *Memory) QueryDocuments(namespace string, typename string,
+	terms map[string]interface{}) ([]Document, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var ids []string
+	for id := range m.namespaces[namespace][typename] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var documents []Document
+	for _, id := range ids {
+		b := m.namespaces[namespace][typename][id]
+		ok, err := matchTerms(b, terms)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			documents = append(documents, Document{Type: typename, ID: id, Body: &b})
+		}
+	}
+	return documents, nil
+}
+
+// Delete removes a document.
+func (m *Memory) Delete(namespace string, typename string, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.namespaces[namespace][typename], id)
+	return nil
+}
+
+// DeleteQuery removes the documents of a type whose top level fields are
+// equal to the terms.
+func (m *Memory) DeleteQuery(namespace string, typename string,
+	terms map[string]interface{}) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var count int64
+	for id, b := range m.namespaces[namespace][typename] {
+		ok, err := matchTerms(b, terms)
+		if err != nil {
+			return count, err
+		}
+		if ok {
+			delete(m.namespaces[namespace][typename], id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Flush does nothing: documents are stored by Upsert.
+func (m *Memory) Flush(prefix string, namespaces ...string) error {
+	return nil
+}
+
+func matchTerms(b json.RawMessage, terms map[string]interface{}) (bool, error) {
+	if len(terms) == 0 {
+		return true, nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return false, err
+	}
+	for field, value := range terms {
+		if fmt.Sprint(fields[field]) != fmt.Sprint(value) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,101 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/nlamirault/geronimo/config"
+)
+
+func TestNewBackend(t *testing.T) {
+	conf := &config.Configuration{}
+	conf.Storage.Backend = "memory"
+	backend, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := backend.(*Memory); !ok {
+		t.Fatalf("Invalid backend: %#v", backend)
+	}
+	conf.Storage.Backend = "foo"
+	if _, err := New(conf); err == nil {
+		t.Fatalf("Invalid unknown backend: %#v", conf.Storage)
+	}
+}
+
+func TestMemoryBackend(t *testing.T) {
+	var backend Backend = NewMemory()
+	if err := backend.CreateNamespace("nlamirault_geronimo"); err != nil {
+		t.Fatal(err)
+	}
+	err := backend.BulkUpsert("nlamirault_geronimo", []Document{
+		{Type: "tag", ID: "0.1.0", Body: Tag{Name: "0.1.0", SHA: "abc"}},
+		{Type: "tag", ID: "0.2.0", Body: Tag{Name: "0.2.0", SHA: "def"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = backend.Upsert("nlamirault_geronimo", "tag", "0.1.0",
+		Tag{Name: "0.1.0", SHA: "123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := backend.Count("nlamirault_geronimo", "tag", nil); count != 2 {
+		t.Fatalf("Invalid count: %d", count)
+	}
+	count, _ := backend.Count("nlamirault_geronimo", "tag",
+		map[string]interface{}{"sha": "def"})
+	if count != 1 {
+		t.Fatalf("Invalid count with terms: %d", count)
+	}
+	var tag Tag
+	found, err := backend.Get("nlamirault_geronimo", "tag", "0.1.0", &tag)
+	if err != nil || !found || tag.SHA != "123" {
+		t.Fatalf("Invalid document: %t %#v", found, tag)
+	}
+	documents, err := backend.Query("nlamirault_geronimo", "tag",
+		map[string]interface{}{"sha": "def"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(documents) != 1 {
+		t.Fatalf("Invalid query: %d", len(documents))
+	}
+	if err := json.Unmarshal(*documents[0], &tag); err != nil || tag.Name != "0.2.0" {
+		t.Fatalf("Invalid query result: %#v", tag)
+	}
+	ids, err := backend.QueryDocuments("nlamirault_geronimo", "tag",
+		map[string]interface{}{"sha": "def"})
+	if err != nil || len(ids) != 1 || ids[0].ID != "0.2.0" || ids[0].Type != "tag" {
+		t.Fatalf("Invalid query documents: %#v %v", ids, err)
+	}
+	if err := backend.Delete("nlamirault_geronimo", "tag", "0.2.0"); err != nil {
+		t.Fatal(err)
+	}
+	found, _ = backend.Get("nlamirault_geronimo", "tag", "0.2.0", &tag)
+	if found {
+		t.Fatalf("Invalid deleted document: %#v", tag)
+	}
+	count, err = backend.DeleteQuery("nlamirault_geronimo", "tag",
+		map[string]interface{}{"sha": "123"})
+	if err != nil || count != 1 {
+		t.Fatalf("Invalid deleted documents: %d %v", count, err)
+	}
+	if count, _ := backend.Count("nlamirault_geronimo", "tag", nil); count != 0 {
+		t.Fatalf("Invalid count after delete: %d", count)
+	}
+}
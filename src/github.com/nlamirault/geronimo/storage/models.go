@@ -14,6 +14,10 @@
 
 package storage
 
+import (
+	"time"
+)
+
 // User is the structure used for serializing/deserializing user in Elasticsearch.
 type User struct {
 	Login    string `json:"user"`
@@ -24,14 +28,198 @@ type User struct {
 }
 
 // Repository is the structure used for serializing/deserializing repository in Elasticsearch.
+// Deleted is the date the repository was found deleted from GitHub.
 type Repository struct {
-	Name             string `json:"name"`
-	Description      string `json:"description"`
-	Created          string `json:"created"`
-	Language         string `json:"language"`
-	ForksCount       int    `json:"fork_count"`
-	StarsCount       int    `json:"star_count"`
-	SubscribersCount int    `json:"subscriber_count"`
-	WatchersCount    int    `json:"watcher_count"`
-	OpenIssuesCount  int    `json:"open_issue_count"`
+	Owner            string     `json:"owner"`
+	ID               int        `json:"id"`
+	Name             string     `json:"name"`
+	Description      string     `json:"description"`
+	Created          *time.Time `json:"created,omitempty"`
+	Language         string     `json:"language"`
+	ForksCount       int        `json:"fork_count"`
+	StarsCount       int        `json:"star_count"`
+	SubscribersCount int        `json:"subscriber_count"`
+	WatchersCount    int        `json:"watcher_count"`
+	OpenIssuesCount  int        `json:"open_issue_count"`
+	Deleted          *time.Time `json:"deleted,omitempty"`
+}
+
+// RepositorySnapshot is the structure used for serializing/deserializing the
+// metrics of a repository at a given date in Elasticsearch.
+type RepositorySnapshot struct {
+	Owner            string    `json:"owner"`
+	ID               int       `json:"id"`
+	Name             string    `json:"name"`
+	ForksCount       int       `json:"fork_count"`
+	StarsCount       int       `json:"star_count"`
+	SubscribersCount int       `json:"subscriber_count"`
+	WatchersCount    int       `json:"watcher_count"`
+	OpenIssuesCount  int       `json:"open_issue_count"`
+	Date             time.Time `json:"date"`
+}
+
+// Issue is the structure used for serializing/deserializing issue in Elasticsearch.
+type Issue struct {
+	Owner      string     `json:"owner"`
+	Repository string     `json:"repository"`
+	Number     int        `json:"number"`
+	Title      string     `json:"title"`
+	State      string     `json:"state"`
+	Labels     []string   `json:"labels"`
+	Assignees  []string   `json:"assignees"`
+	Author     string     `json:"author"`
+	Created    *time.Time `json:"created,omitempty"`
+	Closed     *time.Time `json:"closed,omitempty"`
+	Comments   int        `json:"comment_count"`
+}
+
+// PullRequest is the structure used for serializing/deserializing pull request in Elasticsearch.
+// TimeToFirstReview and TimeToMerge are durations in seconds since the creation.
+type PullRequest struct {
+	Owner             string     `json:"owner"`
+	Repository        string     `json:"repository"`
+	Number            int        `json:"number"`
+	Title             string     `json:"title"`
+	State             string     `json:"state"`
+	Author            string     `json:"author"`
+	BaseBranch        string     `json:"base_branch"`
+	HeadBranch        string     `json:"head_branch"`
+	Draft             bool       `json:"draft"`
+	Additions         int        `json:"additions"`
+	Deletions         int        `json:"deletions"`
+	ChangedFiles      int        `json:"changed_files"`
+	Commits           int        `json:"commit_count"`
+	Comments          int        `json:"comment_count"`
+	ReviewComments    int        `json:"review_comment_count"`
+	Reviews           int        `json:"review_count"`
+	Merged            bool       `json:"merged"`
+	MergedBy          string     `json:"merged_by"`
+	Created           *time.Time `json:"created,omitempty"`
+	FirstReviewed     *time.Time `json:"first_reviewed,omitempty"`
+	MergedAt          *time.Time `json:"merged_at,omitempty"`
+	Closed            *time.Time `json:"closed,omitempty"`
+	TimeToFirstReview int64      `json:"time_to_first_review,omitempty"`
+	TimeToMerge       int64      `json:"time_to_merge,omitempty"`
+}
+
+// Commit is the structure used for serializing/deserializing commit in Elasticsearch.
+type Commit struct {
+	Owner          string     `json:"owner"`
+	Repository     string     `json:"repository"`
+	SHA            string     `json:"sha"`
+	Author         string     `json:"author"`
+	AuthorName     string     `json:"author_name"`
+	AuthorEmail    string     `json:"author_email"`
+	Authored       *time.Time `json:"authored,omitempty"`
+	Committer      string     `json:"committer"`
+	CommitterName  string     `json:"committer_name"`
+	CommitterEmail string     `json:"committer_email"`
+	Committed      *time.Time `json:"committed,omitempty"`
+	Message        string     `json:"message"`
+	Additions      int        `json:"additions"`
+	Deletions      int        `json:"deletions"`
+	Files          []string   `json:"files"`
+}
+
+// CommitAuthor is the structure used for serializing/deserializing the
+// commits statistics of an author on a repository in Elasticsearch.
+type CommitAuthor struct {
+	Owner      string     `json:"owner"`
+	Repository string     `json:"repository"`
+	Author     string     `json:"author"`
+	Commits    int        `json:"commit_count"`
+	Additions  int        `json:"additions"`
+	Deletions  int        `json:"deletions"`
+	First      *time.Time `json:"first_commit,omitempty"`
+	Last       *time.Time `json:"last_commit,omitempty"`
+}
+
+// Contributor is the structure used for serializing/deserializing contributor
+// of a repository in Elasticsearch.
+type Contributor struct {
+	Owner         string     `json:"owner"`
+	Repository    string     `json:"repository"`
+	Login         string     `json:"login"`
+	Contributions int        `json:"contribution_count"`
+	First         *time.Time `json:"first_contribution,omitempty"`
+	Last          *time.Time `json:"last_contribution,omitempty"`
+}
+
+// UserContributor is the structure used for serializing/deserializing the
+// contributions of a contributor aggregated across all the repositories of a
+// user in Elasticsearch.
+type UserContributor struct {
+	Owner         string     `json:"owner"`
+	Login         string     `json:"login"`
+	Contributions int        `json:"contribution_count"`
+	Repositories  []string   `json:"repositories"`
+	First         *time.Time `json:"first_contribution,omitempty"`
+	Last          *time.Time `json:"last_contribution,omitempty"`
+}
+
+// Release is the structure used for serializing/deserializing release in Elasticsearch.
+type Release struct {
+	Owner      string     `json:"owner"`
+	Repository string     `json:"repository"`
+	ID         int        `json:"id"`
+	Tag        string     `json:"tag"`
+	Name       string     `json:"name"`
+	Draft      bool       `json:"draft"`
+	Prerelease bool       `json:"prerelease"`
+	Author     string     `json:"author"`
+	Created    *time.Time `json:"created,omitempty"`
+	Published  *time.Time `json:"published,omitempty"`
+	Assets     int        `json:"asset_count"`
+	Downloads  int        `json:"download_count"`
+}
+
+// AssetSnapshot is the structure used for serializing/deserializing the
+// download count of a release asset at a given date in Elasticsearch.
+type AssetSnapshot struct {
+	Owner      string    `json:"owner"`
+	Repository string    `json:"repository"`
+	Release    string    `json:"release"`
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	Downloads  int       `json:"download_count"`
+	Date       time.Time `json:"date"`
+}
+
+// Tag is the structure used for serializing/deserializing tag in Elasticsearch.
+type Tag struct {
+	Owner      string `json:"owner"`
+	Repository string `json:"repository"`
+	Name       string `json:"name"`
+	SHA        string `json:"sha"`
+}
+
+// Star is the structure used for serializing/deserializing star of a
+// repository in Elasticsearch.
+type Star struct {
+	Owner      string     `json:"owner"`
+	Repository string     `json:"repository"`
+	User       string     `json:"user"`
+	StarredAt  *time.Time `json:"starred_at,omitempty"`
+}
+
+// Checkpoint is the structure used for serializing/deserializing the state of
+// the synchronization of a data type of a repository in Elasticsearch.
+type Checkpoint struct {
+	Owner       string     `json:"owner"`
+	Repository  string     `json:"repository"`
+	Type        string     `json:"type"`
+	LastUpdated *time.Time `json:"last_updated,omitempty"`
+	LastRun     *time.Time `json:"last_run,omitempty"`
+}
+
+// Organization is the structure used for serializing/deserializing organization in Elasticsearch.
+type Organization struct {
+	Login       string   `json:"organization"`
+	Name        string   `json:"name"`
+	Company     string   `json:"company"`
+	Email       string   `json:"email"`
+	Location    string   `json:"location"`
+	PublicRepos int      `json:"public_repo_count"`
+	Members     int      `json:"member_count"`
+	Teams       []string `json:"teams"`
 }
@@ -0,0 +1,758 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build sqlite
+// +build sqlite
+
+package storage
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	// Registers the sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/nlamirault/geronimo/config"
+)
+
+func init() {
+	Register("sqlite", func(conf *config.Configuration) (Backend, error) {
+		path := conf.SQLite.Path
+		if path == "" {
+			path = filepath.Join(os.Getenv("HOME"), ".local", "share",
+				"geronimo", "geronimo.db")
+		}
+		return NewSQLite(path)
+	})
+}
+
+// sqliteTables are the tables of the document types. The documents of the
+// other types are stored into the documents table.
+var sqliteTables = map[string]string{
+	"user":                "users",
+	"organization":        "organizations",
+	"repository":          "repositories",
+	"repository_snapshot": "repository_snapshots",
+	"issue":               "issues",
+	"pull_request":        "pull_requests",
+	"commit":              "commits",
+	"commit_author":       "commit_authors",
+	"contributor":         "contributors",
+	"user_contributor":    "user_contributors",
+	"release":             "releases",
+	"asset_snapshot":      "asset_snapshots",
+	"tag":                 "tags",
+	"star":                "stars",
+	"checkpoint":          "checkpoints",
+}
+
+// sqliteDocuments is the table of the documents of the types without table.
+const sqliteDocuments = "documents"
+
+// sqliteMigrations are the migrations of the schema, applied in order. The
+// version of a migration is its position starting from 1: never modify or
+// reorder an existing migration, append a new one.
+//
+// Each row keeps the JSON document with its namespace and ID, and its fields
+// as columns named like the JSON fields. Lists are stored as JSON arrays and
+// dates as RFC 3339 strings. The rows of the documents of a repository
+// reference their repository by its GitHub ID, see sqliteRepositoryTables.
+var sqliteMigrations = []string{
+	`CREATE TABLE namespaces (
+		name TEXT NOT NULL PRIMARY KEY
+	);
+	CREATE TABLE documents (
+		namespace TEXT NOT NULL,
+		type TEXT NOT NULL,
+		id TEXT NOT NULL,
+		document TEXT NOT NULL,
+		PRIMARY KEY (namespace, type, id)
+	);
+	CREATE TABLE users (
+		namespace TEXT NOT NULL,
+		id TEXT NOT NULL,
+		document TEXT NOT NULL,
+		user TEXT,
+		name TEXT,
+		company TEXT,
+		email TEXT,
+		location TEXT,
+		PRIMARY KEY (namespace, id)
+	);
+	CREATE TABLE organizations (
+		namespace TEXT NOT NULL,
+		id TEXT NOT NULL,
+		document TEXT NOT NULL,
+		organization TEXT,
+		name TEXT,
+		company TEXT,
+		email TEXT,
+		location TEXT,
+		public_repo_count INTEGER,
+		member_count INTEGER,
+		teams TEXT,
+		PRIMARY KEY (namespace, id)
+	);
+	CREATE TABLE repositories (
+		namespace TEXT NOT NULL,
+		id TEXT NOT NULL,
+		document TEXT NOT NULL,
+		name TEXT,
+		description TEXT,
+		created TEXT,
+		language TEXT,
+		fork_count INTEGER,
+		star_count INTEGER,
+		subscriber_count INTEGER,
+		watcher_count INTEGER,
+		open_issue_count INTEGER,
+		PRIMARY KEY (namespace, id)
+	);
+	CREATE TABLE repository_snapshots (
+		namespace TEXT NOT NULL,
+		id TEXT NOT NULL,
+		document TEXT NOT NULL,
+		name TEXT,
+		fork_count INTEGER,
+		star_count INTEGER,
+		subscriber_count INTEGER,
+		watcher_count INTEGER,
+		open_issue_count INTEGER,
+		date TEXT,
+		PRIMARY KEY (namespace, id)
+	);
+	CREATE TABLE issues (
+		namespace TEXT NOT NULL,
+		id TEXT NOT NULL,
+		document TEXT NOT NULL,
+		repository TEXT,
+		number INTEGER,
+		title TEXT,
+		state TEXT,
+		labels TEXT,
+		assignees TEXT,
+		author TEXT,
+		created TEXT,
+		closed TEXT,
+		comment_count INTEGER,
+		PRIMARY KEY (namespace, id)
+	);
+	CREATE TABLE pull_requests (
+		namespace TEXT NOT NULL,
+		id TEXT NOT NULL,
+		document TEXT NOT NULL,
+		repository TEXT,
+		number INTEGER,
+		title TEXT,
+		state TEXT,
+		author TEXT,
+		base_branch TEXT,
+		head_branch TEXT,
+		draft INTEGER,
+		additions INTEGER,
+		deletions INTEGER,
+		changed_files INTEGER,
+		commit_count INTEGER,
+		comment_count INTEGER,
+		review_comment_count INTEGER,
+		review_count INTEGER,
+		merged INTEGER,
+		merged_by TEXT,
+		created TEXT,
+		first_reviewed TEXT,
+		merged_at TEXT,
+		closed TEXT,
+		time_to_first_review INTEGER,
+		time_to_merge INTEGER,
+		PRIMARY KEY (namespace, id)
+	);
+	CREATE TABLE commits (
+		namespace TEXT NOT NULL,
+		id TEXT NOT NULL,
+		document TEXT NOT NULL,
+		repository TEXT,
+		sha TEXT,
+		author TEXT,
+		author_name TEXT,
+		author_email TEXT,
+		authored TEXT,
+		committer TEXT,
+		committer_name TEXT,
+		committer_email TEXT,
+		committed TEXT,
+		message TEXT,
+		additions INTEGER,
+		deletions INTEGER,
+		files TEXT,
+		PRIMARY KEY (namespace, id)
+	);
+	CREATE TABLE commit_authors (
+		namespace TEXT NOT NULL,
+		id TEXT NOT NULL,
+		document TEXT NOT NULL,
+		repository TEXT,
+		author TEXT,
+		commit_count INTEGER,
+		additions INTEGER,
+		deletions INTEGER,
+		first_commit TEXT,
+		last_commit TEXT,
+		PRIMARY KEY (namespace, id)
+	);
+	CREATE TABLE contributors (
+		namespace TEXT NOT NULL,
+		id TEXT NOT NULL,
+		document TEXT NOT NULL,
+		repository TEXT,
+		login TEXT,
+		contribution_count INTEGER,
+		repositories TEXT,
+		first_contribution TEXT,
+		last_contribution TEXT,
+		PRIMARY KEY (namespace, id)
+	);
+	CREATE TABLE releases (
+		namespace TEXT NOT NULL,
+		id TEXT NOT NULL,
+		document TEXT NOT NULL,
+		repository TEXT,
+		tag TEXT,
+		name TEXT,
+		draft INTEGER,
+		prerelease INTEGER,
+		author TEXT,
+		created TEXT,
+		published TEXT,
+		asset_count INTEGER,
+		download_count INTEGER,
+		PRIMARY KEY (namespace, id)
+	);
+	CREATE TABLE asset_snapshots (
+		namespace TEXT NOT NULL,
+		id TEXT NOT NULL,
+		document TEXT NOT NULL,
+		repository TEXT,
+		release TEXT,
+		name TEXT,
+		download_count INTEGER,
+		date TEXT,
+		PRIMARY KEY (namespace, id)
+	);
+	CREATE TABLE tags (
+		namespace TEXT NOT NULL,
+		id TEXT NOT NULL,
+		document TEXT NOT NULL,
+		repository TEXT,
+		name TEXT,
+		sha TEXT,
+		PRIMARY KEY (namespace, id)
+	);
+	CREATE TABLE stars (
+		namespace TEXT NOT NULL,
+		id TEXT NOT NULL,
+		document TEXT NOT NULL,
+		repository TEXT,
+		user TEXT,
+		starred_at TEXT,
+		PRIMARY KEY (namespace, id)
+	);
+	CREATE TABLE checkpoints (
+		namespace TEXT NOT NULL,
+		id TEXT NOT NULL,
+		document TEXT NOT NULL,
+		repository TEXT,
+		type TEXT,
+		last_updated TEXT,
+		last_run TEXT,
+		PRIMARY KEY (namespace, id)
+	);`,
+	`ALTER TABLE repositories ADD COLUMN owner TEXT;
+	ALTER TABLE repository_snapshots ADD COLUMN owner TEXT;
+	ALTER TABLE issues ADD COLUMN owner TEXT;
+	ALTER TABLE pull_requests ADD COLUMN owner TEXT;
+	ALTER TABLE commits ADD COLUMN owner TEXT;
+	ALTER TABLE commit_authors ADD COLUMN owner TEXT;
+	ALTER TABLE contributors ADD COLUMN owner TEXT;
+	ALTER TABLE releases ADD COLUMN owner TEXT;
+	ALTER TABLE asset_snapshots ADD COLUMN owner TEXT;
+	ALTER TABLE tags ADD COLUMN owner TEXT;
+	ALTER TABLE stars ADD COLUMN owner TEXT;
+	ALTER TABLE checkpoints ADD COLUMN owner TEXT;
+	CREATE TABLE user_contributors (
+		namespace TEXT NOT NULL,
+		id TEXT NOT NULL,
+		document TEXT NOT NULL,
+		owner TEXT,
+		login TEXT,
+		contribution_count INTEGER,
+		repositories TEXT,
+		first_contribution TEXT,
+		last_contribution TEXT,
+		PRIMARY KEY (namespace, id)
+	);`,
+	`ALTER TABLE repositories ADD COLUMN deleted TEXT;`,
+	`ALTER TABLE contributors DROP COLUMN repositories;
+	ALTER TABLE repositories ADD COLUMN github_id INTEGER;
+	UPDATE repositories SET github_id = json_extract(document, '$.id');
+	DELETE FROM repositories WHERE rowid NOT IN
+		(SELECT MAX(rowid) FROM repositories GROUP BY github_id);
+	CREATE UNIQUE INDEX repositories_github_id ON repositories (github_id);
+	ALTER TABLE repository_snapshots ADD COLUMN repository_id INTEGER
+		REFERENCES repositories (github_id) ON DELETE SET NULL;
+	UPDATE repository_snapshots SET repository_id = (SELECT github_id FROM repositories r
+		WHERE r.owner = repository_snapshots.owner AND r.name = repository_snapshots.name);
+	CREATE INDEX repository_snapshots_repository_id ON repository_snapshots (repository_id);
+	ALTER TABLE issues ADD COLUMN repository_id INTEGER
+		REFERENCES repositories (github_id) ON DELETE SET NULL;
+	UPDATE issues SET repository_id = (SELECT github_id FROM repositories r
+		WHERE r.owner = issues.owner AND r.name = issues.repository);
+	CREATE INDEX issues_repository_id ON issues (repository_id);
+	ALTER TABLE pull_requests ADD COLUMN repository_id INTEGER
+		REFERENCES repositories (github_id) ON DELETE SET NULL;
+	UPDATE pull_requests SET repository_id = (SELECT github_id FROM repositories r
+		WHERE r.owner = pull_requests.owner AND r.name = pull_requests.repository);
+	CREATE INDEX pull_requests_repository_id ON pull_requests (repository_id);
+	ALTER TABLE commits ADD COLUMN repository_id INTEGER
+		REFERENCES repositories (github_id) ON DELETE SET NULL;
+	UPDATE commits SET repository_id = (SELECT github_id FROM repositories r
+		WHERE r.owner = commits.owner AND r.name = commits.repository);
+	CREATE INDEX commits_repository_id ON commits (repository_id);
+	ALTER TABLE commit_authors ADD COLUMN repository_id INTEGER
+		REFERENCES repositories (github_id) ON DELETE SET NULL;
+	UPDATE commit_authors SET repository_id = (SELECT github_id FROM repositories r
+		WHERE r.owner = commit_authors.owner AND r.name = commit_authors.repository);
+	CREATE INDEX commit_authors_repository_id ON commit_authors (repository_id);
+	ALTER TABLE contributors ADD COLUMN repository_id INTEGER
+		REFERENCES repositories (github_id) ON DELETE SET NULL;
+	UPDATE contributors SET repository_id = (SELECT github_id FROM repositories r
+		WHERE r.owner = contributors.owner AND r.name = contributors.repository);
+	CREATE INDEX contributors_repository_id ON contributors (repository_id);
+	ALTER TABLE releases ADD COLUMN repository_id INTEGER
+		REFERENCES repositories (github_id) ON DELETE SET NULL;
+	UPDATE releases SET repository_id = (SELECT github_id FROM repositories r
+		WHERE r.owner = releases.owner AND r.name = releases.repository);
+	CREATE INDEX releases_repository_id ON releases (repository_id);
+	ALTER TABLE asset_snapshots ADD COLUMN repository_id INTEGER
+		REFERENCES repositories (github_id) ON DELETE SET NULL;
+	UPDATE asset_snapshots SET repository_id = (SELECT github_id FROM repositories r
+		WHERE r.owner = asset_snapshots.owner AND r.name = asset_snapshots.repository);
+	CREATE INDEX asset_snapshots_repository_id ON asset_snapshots (repository_id);
+	ALTER TABLE tags ADD COLUMN repository_id INTEGER
+		REFERENCES repositories (github_id) ON DELETE SET NULL;
+	UPDATE tags SET repository_id = (SELECT github_id FROM repositories r
+		WHERE r.owner = tags.owner AND r.name = tags.repository);
+	CREATE INDEX tags_repository_id ON tags (repository_id);
+	ALTER TABLE stars ADD COLUMN repository_id INTEGER
+		REFERENCES repositories (github_id) ON DELETE SET NULL;
+	UPDATE stars SET repository_id = (SELECT github_id FROM repositories r
+		WHERE r.owner = stars.owner AND r.name = stars.repository);
+	CREATE INDEX stars_repository_id ON stars (repository_id);
+	ALTER TABLE checkpoints ADD COLUMN repository_id INTEGER
+		REFERENCES repositories (github_id) ON DELETE SET NULL;
+	UPDATE checkpoints SET repository_id = (SELECT github_id FROM repositories r
+		WHERE r.owner = checkpoints.owner AND r.name = checkpoints.repository);
+	CREATE INDEX checkpoints_repository_id ON checkpoints (repository_id);`,
+}
+
+// sqliteRepositoryTables are the tables of the documents of a repository,
+// with the column of the repository name. Their repository_id column is a
+// foreign key to the GitHub ID of their row in the repositories table.
+var sqliteRepositoryTables = map[string]string{
+	"repository_snapshots": "name",
+	"issues":               "repository",
+	"pull_requests":        "repository",
+	"commits":              "repository",
+	"commit_authors":       "repository",
+	"contributors":         "repository",
+	"releases":             "repository",
+	"asset_snapshots":      "repository",
+	"tags":                 "repository",
+	"stars":                "repository",
+	"checkpoints":          "repository",
+}
+
+// SQLite stores the documents into a SQLite database file.
+type SQLite struct {
+	path string
+	db   *sql.DB
+
+	// columns are the columns of the fields of each table.
+	columns map[string]map[string]bool
+}
+
+// NewSQLite opens a SQLite database, creating it if needed, and migrates its
+// schema.
+func NewSQLite(path string) (*SQLite, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("can't create database directory: %s", err.Error())
+	}
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_journal_mode=WAL&_foreign_keys=1")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite doesn't support concurrent writers: the indexers share one
+	// connection.
+	db.SetMaxOpenConns(1)
+	s := &SQLite{
+		path: path,
+		db:   db,
+	}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("can't migrate database %s: %s", path, err.Error())
+	}
+	if err := s.loadColumns(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close closes the database.
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+// migrate applies the migrations not yet applied to the database.
+func (s *SQLite) migrate() error {
+	_, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		applied TEXT NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+	var version int
+	err = s.db.QueryRow(
+		"SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return err
+	}
+	for i := version; i < len(sqliteMigrations); i++ {
+		log.Printf("[INFO] Apply migration %d to %s", i+1, s.path)
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %s", i+1, err.Error())
+		}
+		_, err = tx.Exec(
+			"INSERT INTO schema_migrations (version, applied) VALUES (?, ?)",
+			i+1, time.Now().UTC().Format(time.RFC3339))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadColumns retrieves the columns of the tables of the document types.
+func (s *SQLite) loadColumns() error {
+	s.columns = map[string]map[string]bool{}
+	for _, table := range sqliteTables {
+		rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+		if err != nil {
+			return err
+		}
+		columns := map[string]bool{}
+		for rows.Next() {
+			var (
+				cid, notnull, pk int
+				name, ctype      string
+				dflt             sql.NullString
+			)
+			if err := rows.Scan(&cid, &name, &ctype, &notnull, &dflt, &pk); err != nil {
+				rows.Close()
+				return err
+			}
+			switch name {
+			case "namespace", "id", "document":
+			default:
+				columns[name] = true
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		s.columns[table] = columns
+	}
+	return nil
+}
+
+// Ping returns the version of SQLite.
+func (s *SQLite) Ping() (string, error) {
+	var version string
+	if err := s.db.QueryRow("SELECT sqlite_version()").Scan(&version); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("SQLite %s (%s)", version, s.path), nil
+}
+
+// CreateNamespace records a namespace.
+func (s *SQLite) CreateNamespace(name string) error {
+	_, err := s.db.Exec(
+		"INSERT OR IGNORE INTO namespaces (name) VALUES (?)", name)
+	return err
+}
+
+// sqlExecer is implemented by sql.DB and sql.Tx.
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// Upsert inserts or replaces a document.
+func (s *SQLite) Upsert(namespace string, typename string, id string,
+	body interface{}) error {
+	return s.upsert(s.db, namespace, typename, id, body)
+}
+
+func (s *SQLite) upsert(db sqlExecer, namespace string, typename string,
+	id string, body interface{}) error {
+	document, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	table, ok := sqliteTables[typename]
+	if !ok {
+		_, err := db.Exec(`INSERT OR REPLACE INTO documents
+			(namespace, type, id, document) VALUES (?, ?, ?, ?)`,
+			namespace, typename, id, string(document))
+		return err
+	}
+	fields, err := decodeFields(document)
+	if err != nil {
+		return err
+	}
+	columns := []string{"namespace", "id", "document"}
+	placeholders := []string{"?", "?", "?"}
+	values := []interface{}{namespace, id, string(document)}
+	for field, value := range fields {
+		if !s.columns[table][field] {
+			continue
+		}
+		columns = append(columns, field)
+		placeholders = append(placeholders, "?")
+		values = append(values, sqliteValue(value))
+	}
+	if table == "repositories" {
+		columns = append(columns, "github_id")
+		placeholders = append(placeholders, "?")
+		values = append(values, sqliteValue(fields["id"]))
+	}
+	if name, ok := sqliteRepositoryTables[table]; ok {
+		columns = append(columns, "repository_id")
+		placeholders = append(placeholders,
+			"(SELECT github_id FROM repositories WHERE owner = ? AND name = ?)")
+		values = append(values, sqliteValue(fields["owner"]), sqliteValue(fields[name]))
+	}
+	_, err = db.Exec(fmt.Sprintf("INSERT OR REPLACE INTO %s (%s) VALUES (%s)",
+		table, strings.Join(columns, ", "), strings.Join(placeholders, ", ")),
+		values...)
+	if err != nil || table != "repositories" {
+		return err
+	}
+	return s.linkRepository(db, fields)
+}
+
+// linkRepository references a repository from the rows of its documents,
+// stored before it or before it was renamed.
+func (s *SQLite) linkRepository(db sqlExecer, fields map[string]interface{}) error {
+	for table, name := range sqliteRepositoryTables {
+		_, err := db.Exec(fmt.Sprintf(
+			"UPDATE %s SET repository_id = ? WHERE owner = ? AND %s = ?", table, name),
+			sqliteValue(fields["id"]), sqliteValue(fields["owner"]),
+			sqliteValue(fields["name"]))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BulkUpsert inserts or replaces several documents in one transaction.
+func (s *SQLite) BulkUpsert(namespace string, documents []Document) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, doc := range documents {
+		if err := s.upsert(tx, namespace, doc.Type, doc.ID, doc.Body); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s %s: %s", doc.Type, doc.ID, err.Error())
+		}
+	}
+	return tx.Commit()
+}
+
+// where returns the table of a type and the condition selecting the documents
+// of a namespace whose fields are equal to the terms. The fields without
+// column are read from the JSON document.
+func (s *SQLite) where(namespace string, typename string,
+	terms map[string]interface{}) (string, string, []interface{}) {
+	table, where := sqliteDocuments, "namespace = ? AND type = ?"
+	args := []interface{}{namespace, typename}
+	if t, ok := sqliteTables[typename]; ok {
+		table, where = t, "namespace = ?"
+		args = args[:1]
+	}
+	for field, value := range terms {
+		if s.columns[table][field] {
+			where += fmt.Sprintf(" AND %s = ?", field)
+		} else {
+			where += " AND json_extract(document, ?) = ?"
+			args = append(args, "$."+field)
+		}
+		args = append(args, sqliteValue(value))
+	}
+	return table, where, args
+}
+
+// Get decodes a document into v.
+func (s *SQLite) Get(namespace string, typename string, id string,
+	v interface{}) (bool, error) {
+	table, where, args := s.where(namespace, typename, nil)
+	var document string
+	err := s.db.QueryRow(
+		fmt.Sprintf("SELECT document FROM %s WHERE %s AND id = ?", table, where),
+		append(args, id)...).Scan(&document)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal([]byte(document), v)
+}
+
+// Count returns the number of documents of a type whose fields are equal to
+// the terms.
+func (s *SQLite) Count(namespace string, typename string,
+	terms map[string]interface{}) (int64, error) {
+	table, where, args := s.where(namespace, typename, terms)
+	var count int64
+	err := s.db.QueryRow(
+		fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", table, where),
+		args...).Scan(&count)
+	return count, err
+}
+
+// Query returns the documents of a type, sorted by ID, whose fields are equal
+// to the terms.
+func (s *SQLite) Query(namespace string, typename string,
+	terms map[string]interface{}) ([]*json.RawMessage, error) {
+	documents, err := s.QueryDocuments(namespace, typename, terms)
+	if err != nil {
+		return nil, err
+	}
+	return bodies(documents), nil
+}
+
+// QueryDocuments returns the documents of a type, sorted by ID, whose fields
+// are equal to the terms, with their IDs.
+func (s *SQLite) QueryDocuments(namespace string, typename string,
+	terms map[string]interface{}) ([]Document, error) {
+	table, where, args := s.where(namespace, typename, terms)
+	rows, err := s.db.Query(fmt.Sprintf(
+		"SELECT id, document FROM %s WHERE %s ORDER BY id", table, where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var documents []Document
+	for rows.Next() {
+		var id, document string
+		if err := rows.Scan(&id, &document); err != nil {
+			return nil, err
+		}
+		raw := json.RawMessage(document)
+		documents = append(documents, Document{Type: typename, ID: id, Body: &raw})
+	}
+	return documents, rows.Err()
+}
+
+// Delete removes a document.
+func (s *SQLite) Delete(namespace string, typename string, id string) error {
+	table, where, args := s.where(namespace, typename, nil)
+	_, err := s.db.Exec(
+		fmt.Sprintf("DELETE FROM %s WHERE %s AND id = ?", table, where),
+		append(args, id)...)
+	return err
+}
+
+// DeleteQuery removes the documents of a type whose fields are equal to the
+// terms.
+func (s *SQLite) DeleteQuery(namespace string, typename string,
+	terms map[string]interface{}) (int64, error) {
+	table, where, args := s.where(namespace, typename, terms)
+	res, err := s.db.Exec(
+		fmt.Sprintf("DELETE FROM %s WHERE %s", table, where), args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Flush does nothing: documents are stored by Upsert.
+func (s *SQLite) Flush(prefix string, namespaces ...string) error {
+	return nil
+}
+
+func decodeFields(document []byte) (map[string]interface{}, error) {
+	var fields map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// sqliteValue converts a JSON value to a column value: numbers are stored as
+// integers when possible, lists and objects as JSON.
+func sqliteValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}, map[string]interface{}:
+		b, _ := json.Marshal(v)
+		return string(b)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return v.Format(time.RFC3339Nano)
+	}
+	return value
+}
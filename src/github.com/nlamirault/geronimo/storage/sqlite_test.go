@@ -0,0 +1,243 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build sqlite
+// +build sqlite
+
+package storage
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestSQLite(t *testing.T) (*SQLite, string) {
+	dir, err := ioutil.TempDir("", "geronimo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "geronimo.db")
+	backend, err := NewSQLite(path)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return backend, dir
+}
+
+func TestSQLiteBackend(t *testing.T) {
+	backend, dir := newTestSQLite(t)
+	defer os.RemoveAll(dir)
+	defer backend.Close()
+
+	if err := backend.CreateNamespace("nlamirault_geronimo"); err != nil {
+		t.Fatal(err)
+	}
+	err := backend.BulkUpsert("nlamirault_geronimo", []Document{
+		{Type: "tag", ID: "0.1.0", Body: Tag{Name: "0.1.0", SHA: "abc"}},
+		{Type: "tag", ID: "0.2.0", Body: Tag{Name: "0.2.0", SHA: "def"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = backend.Upsert("nlamirault_geronimo", "tag", "0.1.0",
+		Tag{Name: "0.1.0", SHA: "123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := backend.Count("nlamirault_geronimo", "tag", nil); count != 2 {
+		t.Fatalf("Invalid count: %d", count)
+	}
+	count, _ := backend.Count("nlamirault_geronimo", "tag",
+		map[string]interface{}{"sha": "def"})
+	if count != 1 {
+		t.Fatalf("Invalid count with terms: %d", count)
+	}
+	var tag Tag
+	found, err := backend.Get("nlamirault_geronimo", "tag", "0.1.0", &tag)
+	if err != nil || !found || tag.SHA != "123" {
+		t.Fatalf("Invalid document: %t %#v", found, tag)
+	}
+	documents, err := backend.Query("nlamirault_geronimo", "tag",
+		map[string]interface{}{"sha": "def"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(documents) != 1 {
+		t.Fatalf("Invalid query: %d", len(documents))
+	}
+	if err := json.Unmarshal(*documents[0], &tag); err != nil || tag.Name != "0.2.0" {
+		t.Fatalf("Invalid query result: %#v", tag)
+	}
+	ids, err := backend.QueryDocuments("nlamirault_geronimo", "tag",
+		map[string]interface{}{"sha": "def"})
+	if err != nil || len(ids) != 1 || ids[0].ID != "0.2.0" || ids[0].Type != "tag" {
+		t.Fatalf("Invalid query documents: %#v %v", ids, err)
+	}
+	if err := backend.Delete("nlamirault_geronimo", "tag", "0.2.0"); err != nil {
+		t.Fatal(err)
+	}
+	found, _ = backend.Get("nlamirault_geronimo", "tag", "0.2.0", &tag)
+	if found {
+		t.Fatalf("Invalid deleted document: %#v", tag)
+	}
+	count, err = backend.DeleteQuery("nlamirault_geronimo", "tag",
+		map[string]interface{}{"sha": "123"})
+	if err != nil || count != 1 {
+		t.Fatalf("Invalid deleted documents: %d %v", count, err)
+	}
+	if count, _ := backend.Count("nlamirault_geronimo", "tag", nil); count != 0 {
+		t.Fatalf("Invalid count after delete: %d", count)
+	}
+}
+
+func TestSQLiteRelationalColumns(t *testing.T) {
+	backend, dir := newTestSQLite(t)
+	defer os.RemoveAll(dir)
+	defer backend.Close()
+
+	created := time.Date(2015, 12, 10, 18, 0, 0, 0, time.UTC)
+	err := backend.Upsert("nlamirault_geronimo", "issue", "7", Issue{
+		Repository: "geronimo",
+		Number:     7,
+		State:      "open",
+		Labels:     []string{"bug", "help wanted"},
+		Created:    &created,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		number  int
+		labels  string
+		opened  string
+		closed  *string
+		comment int
+	)
+	err = backend.db.QueryRow(`SELECT number, labels, created, closed, comment_count
+		FROM issues WHERE namespace = ? AND id = ?`,
+		"nlamirault_geronimo", "7").Scan(&number, &labels, &opened, &closed, &comment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if number != 7 || labels != `["bug","help wanted"]` ||
+		opened != "2015-12-10T18:00:00Z" || closed != nil || comment != 0 {
+		t.Fatalf("Invalid issue row: %d %s %s %v %d",
+			number, labels, opened, closed, comment)
+	}
+	documents, err := backend.Query("nlamirault_geronimo", "issue",
+		map[string]interface{}{"number": 7, "state": "open"})
+	if err != nil || len(documents) != 1 {
+		t.Fatalf("Invalid query: %d %v", len(documents), err)
+	}
+}
+
+func TestSQLiteRepositoryReferences(t *testing.T) {
+	backend, dir := newTestSQLite(t)
+	defer os.RemoveAll(dir)
+	defer backend.Close()
+
+	repositoryID := func(id string) *int64 {
+		var ref *int64
+		err := backend.db.QueryRow(
+			"SELECT repository_id FROM issues WHERE id = ?", id).Scan(&ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ref
+	}
+	issue := Issue{Owner: "nlamirault", Repository: "geronimo", Number: 1}
+	if err := backend.Upsert("nlamirault_geronimo", "issue", "1", issue); err != nil {
+		t.Fatal(err)
+	}
+	if ref := repositoryID("1"); ref != nil {
+		t.Fatalf("Invalid reference without repository: %d", *ref)
+	}
+	// The documents stored before their repository are linked to it
+	repo := Repository{Owner: "nlamirault", ID: 42, Name: "geronimo"}
+	if err := backend.Upsert("nlamirault", "repository", "42", repo); err != nil {
+		t.Fatal(err)
+	}
+	issue.Number = 2
+	if err := backend.Upsert("nlamirault_geronimo", "issue", "2", issue); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"1", "2"} {
+		if ref := repositoryID(id); ref == nil || *ref != 42 {
+			t.Fatalf("Invalid reference of issue %s: %v", id, ref)
+		}
+	}
+	if err := backend.Delete("nlamirault", "repository", "42"); err != nil {
+		t.Fatal(err)
+	}
+	if ref := repositoryID("1"); ref != nil {
+		t.Fatalf("Invalid reference to a deleted repository: %d", *ref)
+	}
+	if backend.columns["contributors"]["repositories"] {
+		t.Fatalf("Invalid contributors columns: %v", backend.columns["contributors"])
+	}
+}
+
+func TestSQLiteUnknownType(t *testing.T) {
+	backend, dir := newTestSQLite(t)
+	defer os.RemoveAll(dir)
+	defer backend.Close()
+
+	err := backend.Upsert("geronimo", "foo", "1", map[string]interface{}{
+		"name": "bar", "draft": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	documents, err := backend.Query("geronimo", "foo",
+		map[string]interface{}{"name": "bar", "draft": true})
+	if err != nil || len(documents) != 1 {
+		t.Fatalf("Invalid query: %d %v", len(documents), err)
+	}
+	if count, _ := backend.Count("geronimo", "tag", nil); count != 0 {
+		t.Fatalf("Invalid count: %d", count)
+	}
+}
+
+func TestSQLiteMigrations(t *testing.T) {
+	backend, dir := newTestSQLite(t)
+	defer os.RemoveAll(dir)
+	err := backend.Upsert("geronimo", "checkpoint", "42_issue",
+		Checkpoint{Repository: "geronimo", Type: "issue"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.Close()
+
+	backend, err = NewSQLite(filepath.Join(dir, "geronimo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+	var version int
+	err = backend.db.QueryRow(
+		"SELECT MAX(version) FROM schema_migrations").Scan(&version)
+	if err != nil || version != len(sqliteMigrations) {
+		t.Fatalf("Invalid schema version: %d %v", version, err)
+	}
+	var checkpoint Checkpoint
+	found, err := backend.Get("geronimo", "checkpoint", "42_issue", &checkpoint)
+	if err != nil || !found || checkpoint.Type != "issue" {
+		t.Fatalf("Invalid checkpoint: %t %#v", found, checkpoint)
+	}
+}
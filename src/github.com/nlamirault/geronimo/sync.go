@@ -16,13 +16,15 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"path/filepath"
 	"strings"
-	//"sync"
+	"sync"
 	"time"
 
 	"github.com/google/go-github/github"
-	"gopkg.in/olivere/elastic.v3"
 
 	"github.com/nlamirault/geronimo/config"
 	gh "github.com/nlamirault/geronimo/providers/github"
@@ -35,7 +37,7 @@ const (
 	DefaultNumFetchProcs = 10
 
 	// DefaultNumIndexProcs is the default number of goroutines indexing data
-	// into Elastic Search in parallel.
+	// into the storage backend in parallel.
 	DefaultNumIndexProcs = 4
 
 	// DefaultFrom is the default starting number for syncing repository items.
@@ -52,12 +54,28 @@ const (
 
 var (
 	toFetch chan *github.Repository
-	toIndex chan *github.Repository
-	// wgFetch sync.WaitGroup
-	// wgIndex sync.WaitGroup
+	toIndex chan *repositoryData
+	wgFetch sync.WaitGroup
+	wgIndex sync.WaitGroup
 	options syncOptions
+	report  *syncReport
 )
 
+// repositoryData is the data retrieved from GitHub for a repository, which
+// is sent from the fetchers to the indexers.
+type repositoryData struct {
+	Repository   *github.Repository
+	Issues       []gh.Issue
+	PullRequests []pullRequestData
+	Commits      []*github.RepositoryCommit
+	Contributors []github.Contributor
+	Releases     []gh.RepositoryRelease
+	Tags         []github.RepositoryTag
+	Stargazers   []gh.Stargazer
+	Unstarred    []string
+	Checkpoints  repositoryCheckpoints
+}
+
 type syncOptions struct {
 
 	// NumFetchProcs is the number of goroutines fetching GitHub data in
@@ -73,44 +91,237 @@ type syncOptions struct {
 
 	// From is the index to start syncing from
 	From int
+
+	// CommitsSince is the date of the oldest commit to retrieve.
+	CommitsSince time.Time
+
+	// MaxCommits is the maximum number of commits retrieved per repository.
+	// Zero means no limit.
+	MaxCommits int
+
+	// Date is the date of the synchronization, used to date the snapshots.
+	Date time.Time
+
+	// Snapshots enables the daily snapshots of the repositories metrics.
+	Snapshots bool
+
+	// DeletedRetention is the number of days the documents of a deleted
+	// repository are kept. Zero keeps them forever.
+	DeletedRetention int
+
+	// SleepPerPage is the number of seconds to sleep between each GitHub
+	// page queried.
+	SleepPerPage int
+
+	// Filter selects the repositories to synchronize.
+	Filter *repositoryFilter
+
+	// Types are the data types to synchronize, or nil for all of them.
+	Types map[string]bool
+
+	// Stop interrupts the synchronization when closed: the repositories
+	// already fetched are stored, the others are skipped.
+	Stop <-chan struct{}
+
+	// Layout names the namespaces and IDs of the documents.
+	Layout *storage.Layout
+
+	// Rebuild retrieves all the data from GitHub, ignoring the checkpoints
+	// and the stored documents, as the indices are rebuilt from scratch.
+	Rebuild bool
+}
+
+// syncTypes are the data types of the repositories which can be
+// synchronized separately.
+var syncTypes = []string{
+	"repository",
+	"issue",
+	"pull_request",
+	"commit",
+	"contributor",
+	"release",
+	"tag",
+	"star",
+}
+
+// Syncs returns true if a data type is synchronized.
+func (o syncOptions) Syncs(datatype string) bool {
+	return o.Types == nil || o.Types[datatype]
 }
 
-func synchronize(conf *config.Configuration) {
-	log.Printf("[DEBUG] Configuration : %v", conf)
-	githubClient := gh.NewClient(conf.Github.APIToken)
-	esClient, err := storage.NewClient(conf.ElasticSearch.Host)
+// openBackend creates the storage backend and checks it is reachable.
+func openBackend(conf *config.Configuration) (storage.Backend, error) {
+	backend, err := storage.New(conf)
 	if err != nil {
-		log.Printf(err.Error())
-		return
+		return nil, err
 	}
-	info, _, err := esClient.Ping(conf.ElasticSearch.Host).Do()
+	info, err := backend.Ping()
 	if err != nil {
-		log.Printf(err.Error())
-		return
+		closeBackend(backend)
+		return nil, err
 	}
-	log.Printf("[DEBUG] Elasticsearch: %s ", info.Version.Number)
-	user, _, err := githubClient.Users.Get(conf.Github.User)
+	log.Printf("[DEBUG] Storage: %s ", info)
+	return backend, nil
+}
+
+// closeBackend stores the pending documents and releases the backend.
+func closeBackend(backend storage.Backend) {
+	if closer, ok := backend.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Printf("[ERROR] Can't close storage: %s", err.Error())
+		}
+	}
+}
+
+// synchronize synchronizes the users and organizations of the configuration.
+// It synchronizes the given data types, or all of them if none is given,
+// until stop is closed.
+func synchronize(conf *config.Configuration, backend storage.Backend, types []string, stop <-chan struct{}) error {
+	log.Printf("[DEBUG] Configuration : %v", conf.Redacted())
+	limiter := gh.NewRateLimiter()
+	cache, err := newGithubCache(conf)
 	if err != nil {
-		log.Printf(err.Error())
-		return
+		return fmt.Errorf("can't create GitHub cache: %s", err.Error())
 	}
-	log.Printf("[DEBUG] Github user: %s", user)
+	githubClient := gh.NewClient(conf.Github.APIToken, limiter, cache)
 	options = syncOptions{
-		NumFetchProcs: DefaultNumFetchProcs,
-		NumIndexProcs: DefaultNumIndexProcs,
-		From:          DefaultFrom,
-		PerPage:       DefaultPerPage,
+		NumFetchProcs:    DefaultNumFetchProcs,
+		NumIndexProcs:    DefaultNumIndexProcs,
+		From:             DefaultFrom,
+		PerPage:          DefaultPerPage,
+		MaxCommits:       conf.Sync.MaxCommits,
+		Date:             time.Now(),
+		Snapshots:        conf.Sync.Snapshots,
+		DeletedRetention: conf.Sync.DeletedRetention,
+		SleepPerPage:     DefaultSleepPerPage,
+		Stop:             stop,
+	}
+	if rebuilder, ok := backend.(storage.Rebuilder); ok {
+		options.Rebuild = rebuilder.Rebuilding()
+	}
+	if len(types) > 0 {
+		options.Types = map[string]bool{}
+		for _, datatype := range types {
+			if !validSyncType(datatype) {
+				return fmt.Errorf("unknown data type %s", datatype)
+			}
+			options.Types[datatype] = true
+		}
+	}
+	options.Filter, err = newRepositoryFilter(conf.Filter)
+	if err != nil {
+		return fmt.Errorf("invalid filter: %s", err.Error())
+	}
+	if err := loadLayout(conf); err != nil {
+		return err
+	}
+	if conf.Sync.CommitsSince != "" {
+		since, err := time.Parse(config.CommitsSinceLayout, conf.Sync.CommitsSince)
+		if err != nil {
+			return fmt.Errorf("invalid commits_since: %s", err.Error())
+		}
+		options.CommitsSince = since
+	}
+	var failed []string
+	for _, login := range conf.Github.AllUsers() {
+		if stopped() {
+			break
+		}
+		if err := synchronizeUser(githubClient, backend, login); err != nil {
+			log.Printf("[ERROR] Synchronization of user %s failed: %s",
+				login, err.Error())
+			failed = append(failed, login)
+		}
+	}
+	for _, login := range conf.Github.Organizations {
+		if stopped() {
+			break
+		}
+		if err := synchronizeOrganization(githubClient, backend, login); err != nil {
+			log.Printf("[ERROR] Synchronization of organization %s failed: %s",
+				login, err.Error())
+			failed = append(failed, login)
+		}
+	}
+	limiter.LogStats()
+	if cache != nil {
+		cache.LogStats()
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("synchronization failed for %v", failed)
+	}
+	return nil
+}
+
+// synchronizeOnce synchronizes the given data types, or all of them, unless
+// another synchronization is running.
+func synchronizeOnce(conf *config.Configuration, backend storage.Backend, types []string) error {
+	lock, err := acquireLock(lockFile(conf))
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+	return synchronize(conf, backend, types, notifyStop())
+}
+
+// loadLayout sets the layout of the documents from the configuration.
+func loadLayout(conf *config.Configuration) error {
+	layout, err := storage.NewLayout(conf.Storage)
+	if err != nil {
+		return fmt.Errorf("invalid storage: %s", err.Error())
+	}
+	options.Layout = layout
+	return nil
+}
+
+// configuredOwners returns the users and organizations of the configuration.
+func configuredOwners(conf *config.Configuration) []string {
+	return append(conf.Github.AllUsers(), conf.Github.Organizations...)
+}
+
+func validSyncType(datatype string) bool {
+	return contains(syncTypes, datatype)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// stopped returns true if the synchronization is interrupted.
+func stopped() bool {
+	select {
+	case <-options.Stop:
+		return true
+	default:
+		return false
 	}
-	toFetch = make(chan *github.Repository, options.NumFetchProcs)
-	toIndex = make(chan *github.Repository, options.NumIndexProcs)
-	execute(user, githubClient, esClient)
 }
 
-func retrieveUserRepositories(client *github.Client, user *github.User) ([]github.Repository, error) {
-	var repos []github.Repository
+// newGithubCache creates the cache of the GitHub responses. It returns nil if
+// the cache is bypassed.
+func newGithubCache(conf *config.Configuration) (*gh.Cache, error) {
+	if noCache {
+		return nil, nil
+	}
+	dir := conf.Github.CacheDir
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".cache", "geronimo")
+	}
+	log.Printf("[DEBUG] GitHub cache: %s", dir)
+	return gh.NewCache(dir, conf.Github.CacheSize*1024*1024)
+}
+
+func retrieveUserRepositories(client *github.Client, user *github.User) ([]gh.Repository, error) {
+	var repos []gh.Repository
 	log.Printf("Options: %#v", options)
 	for page := options.From/options.PerPage + 1; page != 0; {
-		r, resp, err := client.Repositories.List(
+		r, resp, err := gh.ListRepositories(
+			client,
 			*user.Login,
 			&github.RepositoryListOptions{
 				ListOptions: github.ListOptions{
@@ -118,73 +329,280 @@ func retrieveUserRepositories(client *github.Client, user *github.User) ([]githu
 					Page:    page,
 				},
 				Type: "owner"})
-		page = resp.NextPage
 		if err != nil {
-			log.Printf("[ERROR] Retrieve repositories: %s", err.Error())
-			continue
+			return nil, fmt.Errorf("can't retrieve repositories: %s", err.Error())
 		}
 		repos = append(repos, r...)
-		time.Sleep(time.Duration(1) * time.Second)
+		page = nextPage(resp)
 	}
 	return repos, nil
 }
 
-func execute(user *github.User, ghClient *github.Client, esClient *elastic.Client) error {
+// nextPage returns the next page of a GitHub listing, after sleeping
+// options.SleepPerPage seconds.
+func nextPage(resp *github.Response) int {
+	if resp.NextPage != 0 && options.SleepPerPage > 0 {
+		time.Sleep(time.Duration(options.SleepPerPage) * time.Second)
+	}
+	return resp.NextPage
+}
+
+// synchronizeUser synchronizes a user and its repositories.
+func synchronizeUser(ghClient *github.Client, backend storage.Backend, login string) error {
+	user, _, err := ghClient.Users.Get(login)
+	if err != nil {
+		return err
+	}
+	log.Printf("[DEBUG] Github user: %s", user)
 	repos, err := retrieveUserRepositories(ghClient, user)
 	if err != nil {
 		return err
 	}
-
-	err = indexingUser(esClient, user)
+	if err := indexingUser(backend, user); err != nil {
+		return err
+	}
+	reconciled, err := reconcileRepositories(ghClient, backend, *user.Login, repos)
 	if err != nil {
 		return err
 	}
+	reconciled.Log()
+	return execute(*user.Login, filterRepositories(repos), ghClient, backend)
+}
 
-	username := strings.ToLower(*user.Login)
-	for _, repo := range repos {
-		log.Printf("[INFO] Repository: %s", *repo.Name)
-		// toFetch <- &repo
-		// toIndex <- &repo
-		fetchingRepository(ghClient, username, &repo)
-		indexingRepository(esClient, username, &repo)
+// execute synchronizes the repositories of an owner, user or organization.
+func execute(owner string, repos []github.Repository, ghClient *github.Client, backend storage.Backend) error {
+	username := strings.ToLower(owner)
+	toFetch = make(chan *github.Repository, options.NumFetchProcs)
+	toIndex = make(chan *repositoryData, options.NumIndexProcs)
+	report = newSyncReport()
+
+	for i := 0; i < options.NumIndexProcs; i++ {
+		wgIndex.Add(1)
+		go indexer(backend, username)
+	}
+	for i := 0; i < options.NumFetchProcs; i++ {
+		wgFetch.Add(1)
+		go fetcher(ghClient, backend, username)
 	}
 
-	log.Printf("[INFO] Done indexing repositories in ElasticSearch")
-	return nil
+feed:
+	for i := range repos {
+		log.Printf("[INFO] Repository: %s", *repos[i].Name)
+		select {
+		case toFetch <- &repos[i]:
+		case <-options.Stop:
+			log.Printf("[INFO] Synchronization interrupted, %d repositories skipped",
+				len(repos)-i)
+			break feed
+		}
+	}
+
+	// Fetchers stop when toFetch is drained, then indexers stop when
+	// everything the fetchers produced has been stored.
+	close(toFetch)
+	wgFetch.Wait()
+	close(toIndex)
+	wgIndex.Wait()
+
+	if options.Syncs("contributor") {
+		if err := indexingUserContributors(backend, username); err != nil {
+			report.Failure(owner, err)
+		}
+	}
+	if err := backend.Flush(""); err != nil {
+		report.Failure(owner, err)
+	}
+
+	report.Log()
+	log.Printf("[INFO] Done indexing repositories")
+	return report.Err()
+}
+
+// fetcher retrieves data of repositories from toFetch and sends them to the
+// indexers.
+func fetcher(client *github.Client, backend storage.Backend, username string) {
+	defer wgFetch.Done()
+	for repo := range toFetch {
+		data, err := fetchingRepository(client, backend, username, repo)
+		if err != nil {
+			report.Failure(*repo.Name, err)
+			continue
+		}
+		toIndex <- data
+	}
 }
 
-func indexingUser(client *elastic.Client, user *github.User) error {
+// indexer stores the repositories received from toIndex.
+func indexer(backend storage.Backend, username string) {
+	defer wgIndex.Done()
+	for data := range toIndex {
+		name := *data.Repository.Name
+		if err := indexingRepository(backend, username, data); err != nil {
+			report.Failure(name, err)
+			continue
+		}
+		report.Success(name)
+	}
+}
+
+func indexingUser(backend storage.Backend, user *github.User) error {
 	data := storage.User{
 		Login:    *user.Login,
-		Name:     *user.Name,
-		Company:  *user.Company,
-		Email:    *user.Email,
-		Location: *user.Location,
+		Name:     stringValue(user.Name),
+		Company:  stringValue(user.Company),
+		Email:    stringValue(user.Email),
+		Location: stringValue(user.Location),
+	}
+	owner := strings.ToLower(*user.Login)
+	index := options.Layout.Index("user", owner, "")
+	if err := backend.CreateNamespace(index); err != nil {
+		return fmt.Errorf("can't create index: %s", err.Error())
 	}
-	_, err := storage.Save(
-		client, *user.Login, "user", fmt.Sprintf("%d", *user.ID), data)
-	return err
+	return backend.Upsert(index, "user",
+		options.Layout.ID(owner, "", fmt.Sprintf("%d", *user.ID)), data)
 }
 
-func fetchingRepository(client *github.Client, username string, repo *github.Repository) {
-	//repo := <-toFetch
+func fetchingRepository(client *github.Client, backend storage.Backend, username string, repo *github.Repository) (*repositoryData, error) {
 	log.Printf("[INFO] Fetch repository: %s", *repo.Name)
+	var datatypes []string
+	for _, datatype := range []string{"issue", "pull_request", "commit"} {
+		if options.Syncs(datatype) {
+			datatypes = append(datatypes, datatype)
+		}
+	}
+	checkpoints, err := loadCheckpoints(backend, username, repo, datatypes...)
+	if err != nil {
+		return nil, fmt.Errorf("can't retrieve checkpoints: %s", err.Error())
+	}
+	data := &repositoryData{
+		Repository:  repo,
+		Checkpoints: checkpoints,
+	}
+	if options.Syncs("issue") {
+		data.Issues, err = retrieveRepositoryIssues(
+			client, repo, checkpoints.Since("issue"))
+		if err != nil {
+			return nil, fmt.Errorf("can't retrieve issues: %s", err.Error())
+		}
+		for _, issue := range data.Issues {
+			checkpoints.Update("issue", issue.UpdatedAt)
+		}
+	}
+	if options.Syncs("pull_request") {
+		data.PullRequests, err = retrieveRepositoryPullRequests(
+			client, repo, checkpoints.Since("pull_request"))
+		if err != nil {
+			return nil, fmt.Errorf("can't retrieve pull requests: %s", err.Error())
+		}
+		for _, pull := range data.PullRequests {
+			checkpoints.Update("pull_request", pull.PullRequest.UpdatedAt)
+		}
+	}
+	if options.Syncs("commit") {
+		since := options.CommitsSince
+		if last := checkpoints.Since("commit").Add(-commitsOverlap); last.After(since) {
+			since = last
+		}
+		var complete bool
+		data.Commits, complete, err = retrieveRepositoryCommits(
+			client, backend, username, repo, since)
+		if err != nil {
+			return nil, fmt.Errorf("can't retrieve commits: %s", err.Error())
+		}
+		// The commits left out by max_commits are older than the retrieved
+		// ones, so the checkpoint waits for them
+		for _, commit := range data.Commits {
+			if complete && commit.Commit != nil && commit.Commit.Committer != nil {
+				checkpoints.Update("commit", commit.Commit.Committer.Date)
+			}
+		}
+	}
+	if options.Syncs("contributor") {
+		data.Contributors, err = retrieveRepositoryContributors(client, repo)
+		if err != nil {
+			return nil, fmt.Errorf("can't retrieve contributors: %s", err.Error())
+		}
+	}
+	if options.Syncs("release") {
+		data.Releases, err = retrieveRepositoryReleases(client, repo)
+		if err != nil {
+			return nil, fmt.Errorf("can't retrieve releases: %s", err.Error())
+		}
+	}
+	if options.Syncs("tag") {
+		data.Tags, err = retrieveRepositoryTags(client, repo)
+		if err != nil {
+			return nil, fmt.Errorf("can't retrieve tags: %s", err.Error())
+		}
+	}
+	if options.Syncs("star") {
+		data.Stargazers, data.Unstarred, err = retrieveRepositoryStargazers(
+			client, backend, username, repo)
+		if err != nil {
+			return nil, fmt.Errorf("can't retrieve stargazers: %s", err.Error())
+		}
+	}
+	return data, nil
 }
 
-func indexingRepository(client *elastic.Client, username string, repo *github.Repository) {
-	//repo := <-toIndex
+func indexingRepository(backend storage.Backend, username string, data *repositoryData) error {
+	repo := data.Repository
 	log.Printf("[INFO] Index repository: %s", *repo.Name)
-	err := storage.CreateIndex(
-		client, strings.ToLower(fmt.Sprintf("%s_%s", username, *repo.Name)))
+	indices := append(options.Layout.Indices(username, *repo.Name),
+		options.Layout.Index("repository", username, ""))
+	for _, index := range indices {
+		if err := backend.CreateNamespace(index); err != nil {
+			return fmt.Errorf("can't create index: %s", err.Error())
+		}
+	}
+	if options.Syncs("repository") {
+		if err := saveRepository(backend, username, repo); err != nil {
+			return err
+		}
+	}
+	if err := saveIssues(backend, username, repo, data.Issues); err != nil {
+		return err
+	}
+	if err := savePullRequests(backend, username, repo, data.PullRequests); err != nil {
+		return err
+	}
+	if err := saveCommits(backend, username, repo, data.Commits); err != nil {
+		return err
+	}
+	if options.Syncs("commit") || options.Syncs("contributor") {
+		authors, err := indexingCommitAuthors(backend, username, repo)
+		if err != nil {
+			return err
+		}
+		if options.Syncs("contributor") {
+			roster := newContributors(repo, data.Contributors, authors)
+			if err := saveContributors(backend, username, repo, roster); err != nil {
+				return err
+			}
+		}
+	}
+	if err := saveReleases(backend, username, repo, data.Releases); err != nil {
+		return err
+	}
+	if err := saveTags(backend, username, repo, data.Tags); err != nil {
+		return err
+	}
+	if err := saveStars(backend, username, repo, data.Stargazers, data.Unstarred); err != nil {
+		return err
+	}
+	// Checkpoints are only saved once the data is stored. The namespaces
+	// may be shared with the other repositories, which are indexed
+	// concurrently: only the failures of this repository's documents are
+	// its own.
+	err := backend.Flush(options.Layout.ID(username, *repo.Name, ""),
+		options.Layout.Indices(username, *repo.Name)...)
 	if err != nil {
-		log.Printf("[ERROR] Can't create index for repository %s: %s",
-			*repo.Name, err.Error())
-		return
+		return err
 	}
-	saveRepository(client, username, repo)
+	return saveCheckpoints(backend, username, repo, data.Checkpoints)
 }
 
-func saveRepository(client *elastic.Client, username string, repo *github.Repository) {
+func saveRepository(backend storage.Backend, username string, repo *github.Repository) error {
 	lang := "None"
 	if repo.Language != nil {
 		lang = *repo.Language
@@ -209,10 +627,16 @@ func saveRepository(client *elastic.Client, username string, repo *github.Reposi
 	if repo.OpenIssuesCount != nil {
 		openissues = *repo.OpenIssuesCount
 	}
+	var created *time.Time
+	if repo.CreatedAt != nil {
+		created = &repo.CreatedAt.Time
+	}
 	data := storage.Repository{
+		Owner:            username,
+		ID:               *repo.ID,
 		Name:             *repo.Name,
-		Description:      *repo.Description,
-		Created:          fmt.Sprintf("%s", *repo.CreatedAt),
+		Description:      stringValue(repo.Description),
+		Created:          created,
 		ForksCount:       forks,
 		StarsCount:       stars,
 		SubscribersCount: subscribers,
@@ -221,11 +645,55 @@ func saveRepository(client *elastic.Client, username string, repo *github.Reposi
 		Language:         lang,
 	}
 	log.Printf("[INFO] Store data : %#v", data)
-	put, err := storage.Save(
-		client, username, "repository", fmt.Sprintf("%d", *repo.ID), data)
+	index := options.Layout.Index("repository", username, "")
+	err := backend.Upsert(index, "repository",
+		options.Layout.ID(username, "", fmt.Sprintf("%d", *repo.ID)), data)
 	if err != nil {
-		log.Printf("[ERROR] %s", err.Error())
+		return err
+	}
+	log.Printf("[INFO] Indexed repository %s to index %s\n", data.Name, index)
+	if !options.Snapshots {
+		return nil
+	}
+	snapshot := newRepositorySnapshot(*repo.ID, data, options.Date)
+	return backend.Upsert(
+		options.Layout.Index("repository_snapshot", username, data.Name),
+		"repository_snapshot",
+		options.Layout.ID(username, data.Name, snapshotID(*repo.ID, options.Date)),
+		snapshot)
+}
+
+func newRepositorySnapshot(id int, repo storage.Repository, date time.Time) storage.RepositorySnapshot {
+	return storage.RepositorySnapshot{
+		Owner:            repo.Owner,
+		ID:               id,
+		Name:             repo.Name,
+		ForksCount:       repo.ForksCount,
+		StarsCount:       repo.StarsCount,
+		SubscribersCount: repo.SubscribersCount,
+		WatchersCount:    repo.WatchersCount,
+		OpenIssuesCount:  repo.OpenIssuesCount,
+		Date:             date,
+	}
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func intValue(i *int) int {
+	if i == nil {
+		return 0
+	}
+	return *i
+}
+
+func userLogin(user *github.User) string {
+	if user == nil || user.Login == nil {
+		return ""
 	}
-	log.Printf("[INFO] Indexed repository %s to index %s, type %s\n",
-		put.Id, put.Index, put.Type)
+	return *user.Login
 }
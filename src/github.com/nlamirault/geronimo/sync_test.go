@@ -0,0 +1,40 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nlamirault/geronimo/storage"
+)
+
+func TestNewRepositorySnapshot(t *testing.T) {
+	date := time.Date(2015, 12, 10, 18, 0, 0, 0, time.UTC)
+	repo := storage.Repository{
+		Owner:           "nlamirault",
+		Name:            "geronimo",
+		StarsCount:      12,
+		ForksCount:      3,
+		OpenIssuesCount: 1,
+	}
+	snapshot := newRepositorySnapshot(42, repo, date)
+	if snapshot.ID != 42 || snapshot.Owner != "nlamirault" ||
+		snapshot.Name != "geronimo" ||
+		snapshot.StarsCount != 12 || snapshot.ForksCount != 3 ||
+		snapshot.OpenIssuesCount != 1 || !snapshot.Date.Equal(date) {
+		t.Fatalf("Invalid repository snapshot: %#v", snapshot)
+	}
+}
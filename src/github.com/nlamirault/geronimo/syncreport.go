@@ -0,0 +1,108 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/nlamirault/geronimo/storage"
+)
+
+// syncReport collects the result of the synchronization of each repository.
+// It is safe for concurrent use by the fetchers and the indexers.
+type syncReport struct {
+	mu        sync.Mutex
+	succeeded []string
+	failed    map[string][]error
+}
+
+func newSyncReport() *syncReport {
+	return &syncReport{
+		failed: map[string][]error{},
+	}
+}
+
+// Success records a repository successfully synchronized.
+func (r *syncReport) Success(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.succeeded = append(r.succeeded, name)
+}
+
+// Failure records an error which occurs for a repository. The documents
+// of a bulk error are recorded as distinct errors.
+func (r *syncReport) Failure(name string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if bulkErr, ok := err.(*storage.BulkError); ok {
+		for _, failure := range bulkErr.Failures {
+			r.failed[name] = append(r.failed[name], failure)
+		}
+		return
+	}
+	r.failed[name] = append(r.failed[name], err)
+}
+
+// Succeeded returns the sorted names of the repositories synchronized.
+func (r *syncReport) Succeeded() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, len(r.succeeded))
+	copy(names, r.succeeded)
+	sort.Strings(names)
+	return names
+}
+
+// Failed returns the sorted names of the repositories in error.
+func (r *syncReport) Failed() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var names []string
+	for name := range r.failed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Err returns an error if at least one repository failed.
+func (r *syncReport) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d repositories failed: %v", len(failed), failed)
+}
+
+// Log writes the summary of the synchronization.
+func (r *syncReport) Log() {
+	succeeded := r.Succeeded()
+	failed := r.Failed()
+	log.Printf("[INFO] Synchronization: %d succeeded, %d failed",
+		len(succeeded), len(failed))
+	for _, name := range succeeded {
+		log.Printf("[DEBUG] Repository %s synchronized", name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, name := range failed {
+		for _, err := range r.failed[name] {
+			log.Printf("[ERROR] Repository %s: %s", name, err.Error())
+		}
+	}
+}
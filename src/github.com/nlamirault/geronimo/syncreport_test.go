@@ -0,0 +1,63 @@
+// Copyright (C) 2015 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nlamirault/geronimo/storage"
+)
+
+func TestSyncReport(t *testing.T) {
+	report := newSyncReport()
+	report.Success("foo")
+	report.Success("bar")
+	report.Failure("baz", errors.New("fetch failed"))
+	report.Failure("baz", errors.New("index failed"))
+
+	succeeded := report.Succeeded()
+	if len(succeeded) != 2 || succeeded[0] != "bar" || succeeded[1] != "foo" {
+		t.Fatalf("Invalid succeeded repositories: %v", succeeded)
+	}
+	failed := report.Failed()
+	if len(failed) != 1 || failed[0] != "baz" {
+		t.Fatalf("Invalid failed repositories: %v", failed)
+	}
+	if report.Err() == nil {
+		t.Fatalf("Report without error")
+	}
+}
+
+func TestSyncReportWithoutFailure(t *testing.T) {
+	report := newSyncReport()
+	report.Success("foo")
+	if err := report.Err(); err != nil {
+		t.Fatalf("Invalid report error: %s", err)
+	}
+}
+
+func TestSyncReportBulkFailures(t *testing.T) {
+	report := newSyncReport()
+	report.Failure("foo", &storage.BulkError{
+		Failures: []storage.BulkFailure{
+			{Index: "nlamirault_foo", Type: "issue", ID: "1", Status: 400},
+			{Index: "nlamirault_foo", Type: "issue", ID: "2", Status: 400},
+		},
+	})
+	if len(report.failed["foo"]) != 2 {
+		t.Fatalf("Invalid bulk failures: %v", report.failed["foo"])
+	}
+}